@@ -0,0 +1,119 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package main
+
+import (
+	"os"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/midonet/midonet-kubernetes/pkg/config"
+)
+
+// newLeaderLock creates the resource lock for the leader election.
+func newLeaderLock(config *config.Config, kc *kubernetes.Clientset, recorder record.EventRecorder) resourcelock.Interface {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.WithError(err).Fatal("Hostname")
+	}
+	id := hostname + "_" + uuid.New().String()
+	// REVISIT: Use Lease lock when we drop Kubernetes v1.10 support.
+	lock, err := resourcelock.New(config.LeaderElectLockType, config.LeaderElectNamespace, config.LeaderElectName, kc.CoreV1(), resourcelock.ResourceLockConfig{
+		Identity:      id,
+		EventRecorder: recorder,
+	})
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"identity":  id,
+			"lockType":  config.LeaderElectLockType,
+			"namespace": config.LeaderElectNamespace,
+			"name":      config.LeaderElectName,
+		}).Fatal("Failed to create a resource lock")
+	}
+	return lock
+}
+
+// runWithLeaderElection calls run when this process becomes the leader.
+// It never returns.  Losing the leadership is fatal unless the stop
+// channel has been closed.
+func runWithLeaderElection(config *config.Config, lock resourcelock.Interface, stop <-chan struct{}, run func()) {
+	clog := log.WithFields(log.Fields{
+		"identity": lock.Identity(),
+		"lock":     lock.Describe(),
+	})
+	clog.Info("Waiting for the leadership")
+	leaderelection.RunOrDie(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: config.LeaderElectLeaseDuration,
+		RenewDeadline: config.LeaderElectRenewDeadline,
+		RetryPeriod:   config.LeaderElectRetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(_ <-chan struct{}) {
+				clog.Info("Started leading")
+				run()
+			},
+			OnStoppedLeading: func() {
+				select {
+				case <-stop:
+					// We are shutting down and released the lock.
+					clog.Info("Stopped leading")
+					return
+				default:
+				}
+				// Exit and let Kubernetes restart us as a standby.
+				clog.Fatal("Lost the leadership")
+			},
+		},
+	})
+}
+
+// releaseLeadership gives up the leadership if we have it, so that
+// a standby can take over without waiting for the lease to expire.
+// It should be called after the controllers stopped.
+// Note: With client-go 7.0, standbys still wait for the lease duration
+// since they observed the release.
+func releaseLeadership(lock resourcelock.Interface) {
+	clog := log.WithFields(log.Fields{
+		"identity": lock.Identity(),
+		"lock":     lock.Describe(),
+	})
+	record, err := lock.Get()
+	if err != nil {
+		clog.WithError(err).Warn("Failed to get the leader election record")
+		return
+	}
+	if record.HolderIdentity != lock.Identity() {
+		return
+	}
+	now := metav1.Now()
+	err = lock.Update(resourcelock.LeaderElectionRecord{
+		LeaderTransitions:    record.LeaderTransitions,
+		LeaseDurationSeconds: 1,
+		AcquireTime:          now,
+		RenewTime:            now,
+	})
+	if err != nil {
+		clog.WithError(err).Warn("Failed to release the leadership")
+		return
+	}
+	clog.Info("Released the leadership")
+}
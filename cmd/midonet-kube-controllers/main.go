@@ -16,7 +16,12 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/projectcalico/libcalico-go/lib/logutils"
 	log "github.com/sirupsen/logrus"
@@ -24,18 +29,22 @@ import (
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/scheme"
 	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/client-go/tools/record"
 
 	mnscheme "github.com/midonet/midonet-kubernetes/pkg/client/clientset/versioned/scheme"
 	mninformers "github.com/midonet/midonet-kubernetes/pkg/client/informers/externalversions"
-	"github.com/midonet/midonet-kubernetes/pkg/config"
+	cfg "github.com/midonet/midonet-kubernetes/pkg/config"
 	"github.com/midonet/midonet-kubernetes/pkg/controller"
 	"github.com/midonet/midonet-kubernetes/pkg/converter"
 	"github.com/midonet/midonet-kubernetes/pkg/converter/endpoints"
+	"github.com/midonet/midonet-kubernetes/pkg/converter/networkpolicy"
 	"github.com/midonet/midonet-kubernetes/pkg/converter/node"
 	"github.com/midonet/midonet-kubernetes/pkg/converter/pod"
 	"github.com/midonet/midonet-kubernetes/pkg/converter/service"
+	"github.com/midonet/midonet-kubernetes/pkg/health"
 	"github.com/midonet/midonet-kubernetes/pkg/k8s"
+	"github.com/midonet/midonet-kubernetes/pkg/loadbalancer"
 	"github.com/midonet/midonet-kubernetes/pkg/midonet"
 	"github.com/midonet/midonet-kubernetes/pkg/nodeannotator"
 	"github.com/midonet/midonet-kubernetes/pkg/pusher"
@@ -49,11 +58,11 @@ func main() {
 	log.AddHook(&logutils.ContextHook{})
 
 	// Attempt to load configuration.
-	config := new(config.Config)
-	if err := config.Parse(); err != nil {
-		log.WithError(err).Fatal("Failed to parse config")
+	config, err := cfg.Load()
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load config")
 	}
-	log.WithField("config", config).Info("Loaded configuration from environment")
+	log.WithField("config", config.Redacted()).Info("Loaded configuration")
 
 	// Set the log level based on the loaded configuration.
 	logLevel, err := log.ParseLevel(config.LogLevel)
@@ -68,9 +77,16 @@ func main() {
 		log.WithError(err).Fatal("Failed to start")
 	}
 
+	// Note: Validated by config.Load.
+	workers, _ := cfg.ParseWorkers(config.ControllerWorkers)
+
 	converterCfg := converter.NewConfigFromEnvConfig(config)
 	midonetCfg := midonet.NewConfigFromEnvConfig(config)
 
+	synced := health.NewFlag("informer caches are not synced yet")
+	leading := health.NewFlag("not leading")
+	go serveMetrics(newHealthChecks(config, midonetCfg, synced, leading))
+
 	mnscheme.AddToScheme(scheme.Scheme)
 	broadcaster := record.NewBroadcaster()
 	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "midonet-kube-controllers"})
@@ -80,13 +96,22 @@ func main() {
 	})
 	broadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: k8sClientset.CoreV1().Events("")})
 
-	err = converter.EnsureGlobalResources(mnClientset, converterCfg, recorder)
-	if err != nil {
-		log.WithError(err).Fatal("EnsureGlobalResources")
-	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
 
+	// Closing stop stops the informers and the controllers.
 	stop := make(chan struct{})
-	defer close(stop)
+
+	if config.ConfigFile != "" {
+		// Compare with the last loaded configuration rather than
+		// the startup one, so that a change is warned only once.
+		// Note: Watch calls onChange sequentially.
+		lastConfig := config
+		go cfg.Watch(config.ConfigFile, config.ConfigFileCheckInterval, stop, func(newConfig *cfg.Config) {
+			reload(lastConfig, newConfig, converterCfg)
+			lastConfig = newConfig
+		})
+	}
 
 	si := informers.NewSharedInformerFactory(k8sClientset, 0)
 	msi := mninformers.NewSharedInformerFactory(mnClientset, 0)
@@ -102,12 +127,19 @@ func main() {
 			newController = service.NewController
 		case "endpoints":
 			newController = endpoints.NewController
+		case "networkpolicy":
+			newController = networkpolicy.NewController
 		case "pusher":
 			newController = pusher.NewController
 		case "nodeannotator":
 			newController = nodeannotator.NewController
+		case "loadbalancer":
+			newController = loadbalancer.NewController
 		}
 		c := newController(si, msi, k8sClientset, mnClientset, recorder, converterCfg, midonetCfg)
+		if n, ok := workers[controllerType]; ok {
+			c.SetWorkers(n)
+		}
 		controllers = append(controllers, c)
 	}
 
@@ -118,11 +150,84 @@ func main() {
 	msi.Start(stop)
 	msi.WaitForCacheSync(stop)
 	log.Info("Translation Cache synced")
+	synced.Set()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	stopping := false
+	run := func() {
+		leading.Set()
+		err := converter.EnsureGlobalResources(mnClientset, converterCfg, recorder)
+		if err != nil {
+			log.WithError(err).Fatal("EnsureGlobalResources")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if stopping {
+			return
+		}
+		for _, c := range controllers {
+			wg.Add(1)
+			go func(c *controller.Controller) {
+				defer wg.Done()
+				c.Run(stop)
+			}(c)
+		}
+	}
+	var lock resourcelock.Interface
+	if config.LeaderElect {
+		// Note: Standbys keep their informers warm to take over quickly.
+		lock = newLeaderLock(config, k8sClientset, recorder)
+		go runWithLeaderElection(config, lock, stop, run)
+	} else {
+		run()
+	}
+
+	sig := <-signals
+	log.WithField("signal", sig).Info("Shutting down")
+	mu.Lock()
+	stopping = true
+	mu.Unlock()
+	close(stop)
+	if !waitTimeout(&wg, config.ShutdownTimeout) {
+		log.WithField("timeout", config.ShutdownTimeout).Warn("Timed out waiting for the controllers to stop")
+		// Interrupt the backend operations still in flight.
+		for _, c := range controllers {
+			c.Cancel()
+		}
+	}
+	if lock != nil {
+		releaseLeadership(lock)
+	}
+	log.Info("Exiting")
+}
 
-	for _, c := range controllers {
-		go c.Run()
+// waitTimeout waits for the WaitGroup.  It returns false on timeout.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
 	}
+}
 
-	// Wait forever.
-	serveMetrics()
+// reload applies the reloadable settings in the new configuration.
+// config is the previously loaded configuration.
+// See config.ReloadableFields.
+func reload(config *cfg.Config, newConfig *cfg.Config, converterCfg *converter.Config) {
+	if changed := cfg.NonReloadableChanges(config, newConfig); len(changed) > 0 {
+		log.WithField("fields", changed).Warn("Some changes need a restart to take effect")
+	}
+	logLevel, err := log.ParseLevel(newConfig.LogLevel)
+	if err == nil {
+		log.SetLevel(logLevel)
+	}
+	converterCfg.SetReloadable(converter.NewReloadableConfigFromEnvConfig(newConfig))
+	log.WithField("config", converterCfg.Reloadable()).Info("Reloaded configuration")
 }
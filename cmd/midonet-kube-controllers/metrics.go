@@ -16,15 +16,52 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	_ "net/http/pprof" // Link pprof
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/midonet/midonet-kubernetes/pkg/config"
+	"github.com/midonet/midonet-kubernetes/pkg/health"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
 )
 
-func serveMetrics() {
+func serveMetrics(checks *health.Checks) {
 	http.Handle("/metrics", promhttp.Handler())
+	checks.Install(http.DefaultServeMux)
 	// https://github.com/prometheus/prometheus/wiki/Default-port-allocations
 	log.Fatal(http.ListenAndServe(":9453", nil))
 }
+
+// healthCheckTimeout is the timeout of the midonet-api check.  It's
+// same as timeoutSeconds of the probes in the manifest.
+const healthCheckTimeout = 5 * time.Second
+
+// newHealthChecks creates the checks for /healthz and /readyz.
+// The given Flags are set by the caller when the informer caches are
+// synced and when this process starts leading.
+func newHealthChecks(config *config.Config, midonetCfg *midonet.Config, synced *health.Flag, leading *health.Flag) *health.Checks {
+	checks := health.NewChecks()
+	checks.AddReadinessCheck("informer-sync", synced.Check)
+	client := midonet.NewClient(midonetCfg)
+	checks.AddReadinessCheck("midonet-api", func() (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+		defer cancel()
+		return "", client.Ping(ctx)
+	})
+	// Note: Standbys are reported ready.  Otherwise a rolling update
+	// of the Deployment would never finish.
+	checks.AddReadinessCheck("leader", func() (string, error) {
+		if !config.LeaderElect {
+			return "leader election disabled", nil
+		}
+		if leading.IsSet() {
+			return "leading", nil
+		}
+		return "standby", nil
+	})
+	return checks
+}
@@ -33,6 +33,14 @@ type Config struct {
 	ServiceCIDR string `default:"" split_words:"false"`
 
 	CNIConfigPath string `default:"" split_words:"false"`
+
+	// The NodePort range of the apiserver.  The traffic to the range
+	// arriving on the host is forwarded to the MidoNet network.
+	// An empty string disables it.
+	NodePortRange string `default:"30000-32767" split_words:"false"`
+
+	// TCP port to serve /healthz and /readyz.  0 disables them.
+	HealthPort int `default:"9454" split_words:"false"`
 }
 
 // Parse parses envconfig and stores in Config struct
@@ -0,0 +1,70 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/midonet/midonet-kubernetes/pkg/health"
+	api "github.com/midonet/midonet-kubernetes/pkg/nodeapi"
+)
+
+func serveHealth(port int, checks *health.Checks) {
+	mux := http.NewServeMux()
+	checks.Install(mux)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), mux))
+}
+
+// newHealthChecks creates the checks for /healthz and /readyz.
+// The given Flags are set by the caller when the RPC server starts
+// serving and when the veth for the node is configured.
+func newHealthChecks(rpcStarted *health.Flag, networkReady *health.Flag, hostVethName string) *health.Checks {
+	checks := health.NewChecks()
+	checks.AddLivenessCheck("rpc", func() (string, error) {
+		// Note: Setting up the node can take long, e.g. when the Node
+		// doesn't have podCIDR yet.  Don't let the kubelet kill us
+		// for that.
+		if !rpcStarted.IsSet() {
+			return "not started yet", nil
+		}
+		conn, err := net.DialTimeout("unix", api.Path, time.Second)
+		if err != nil {
+			return "", err
+		}
+		conn.Close()
+		return "", nil
+	})
+	checks.AddReadinessCheck("rpc-started", rpcStarted.Check)
+	checks.AddReadinessCheck("veth", func() (string, error) {
+		if _, err := networkReady.Check(); err != nil {
+			return "", err
+		}
+		iface, err := net.InterfaceByName(hostVethName)
+		if err != nil {
+			return "", err
+		}
+		if iface.Flags&net.FlagUp == 0 {
+			return "", fmt.Errorf("%s is down", hostVethName)
+		}
+		return "", nil
+	})
+	return checks
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/midonet/midonet-kubernetes/pkg/cni/utils"
 	"github.com/midonet/midonet-kubernetes/pkg/converter"
 	"github.com/midonet/midonet-kubernetes/pkg/converter/node"
+	"github.com/midonet/midonet-kubernetes/pkg/health"
 	"github.com/midonet/midonet-kubernetes/pkg/k8s"
 )
 
@@ -66,6 +67,13 @@ func main() {
 		log.WithError(err).Fatal("Failed to start")
 	}
 
+	rpcStarted := health.NewFlag("RPC server is not started yet")
+	networkReady := health.NewFlag("node network is not configured yet")
+	if config.HealthPort != 0 {
+		checks := newHealthChecks(rpcStarted, networkReady, node.IFName())
+		go serveHealth(config.HealthPort, checks)
+	}
+
 	nodeName := config.NodeName
 	logger := log.WithFields(log.Fields{
 		"nodeName": nodeName,
@@ -111,7 +119,14 @@ retry:
 		logger.WithError(err).Fatal("DoNetworking")
 	}
 	logger = logger.WithField("mac", contVethMAC)
+	if config.NodePortRange != "" {
+		err = setupNodePorts(config.NodePortRange, si.GatewayIP.IP, contVethName)
+		if err != nil {
+			logger.WithError(err).Fatal("setupNodePorts")
+		}
+	}
 	logger.Info("Success")
+	networkReady.Set()
 
 	err = k8s.AddNodeAnnotation(k8sClientset, nodeName, converter.MACAnnotation, contVethMAC)
 	if err != nil {
@@ -136,5 +151,5 @@ retry:
 	// Otherwise, we will exit and be restarted by kubernetes.
 	// Note that DaemonSet manadates restartPolicy=Always.
 	// https://kubernetes.io/docs/concepts/workloads/controllers/daemonset/#pod-template
-	serveRPC(k8sClientset)
+	serveRPC(k8sClientset, rpcStarted)
 }
@@ -0,0 +1,117 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// iptables chains owned by us.
+const (
+	nodePortsChain   = "MIDOKUBE-NODEPORTS"
+	postroutingChain = "MIDOKUBE-POSTROUTING"
+	forwardChain     = "MIDOKUBE-FORWARD"
+)
+
+func iptables(args ...string) error {
+	out, err := exec.Command("iptables", append([]string{"-w"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("iptables %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return nil
+}
+
+// ensureChain creates the chain if it doesn't exist and flushes it.
+func ensureChain(table, chain string, rules [][]string) error {
+	if iptables("-t", table, "-L", chain, "-n") != nil {
+		if err := iptables("-t", table, "-N", chain); err != nil {
+			return err
+		}
+	}
+	if err := iptables("-t", table, "-F", chain); err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if err := iptables(append([]string{"-t", table, "-A", chain}, rule...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureJump inserts the rule at the head of the built-in chain unless
+// it exists.
+func ensureJump(table, chain string, rule []string) error {
+	if iptables(append([]string{"-t", table, "-C", chain}, rule...)...) == nil {
+		return nil
+	}
+	return iptables(append([]string{"-t", table, "-I", chain, "1"}, rule...)...)
+}
+
+// setupNodePorts forwards the traffic to NodePorts of this Node, which
+// arrives on the host's own interfaces, to the MidoNet network.
+// The traffic is DNAT'ed to the gateway IP of the Node, for which
+// the node controller dispatches the traffic to NodePort Services,
+// and masqueraded with the Node IP so that the return traffic comes
+// back to the host.
+// portRange is the NodePort range of the apiserver, e.g. "30000-32767".
+// vethName is the host side interface with the Node IP.
+func setupNodePorts(portRange string, gatewayIP net.IP, vethName string) error {
+	ports := strings.Replace(portRange, "-", ":", 1)
+	gateway := gatewayIP.String()
+	log.WithFields(log.Fields{
+		"ports":   ports,
+		"gateway": gateway,
+	}).Info("Setting up NodePorts")
+	err := ensureChain("nat", nodePortsChain, [][]string{
+		{"-p", "tcp", "--dport", ports, "-j", "DNAT", "--to-destination", gateway},
+		{"-p", "udp", "--dport", ports, "-j", "DNAT", "--to-destination", gateway},
+	})
+	if err != nil {
+		return err
+	}
+	err = ensureChain("nat", postroutingChain, [][]string{
+		{"-o", vethName, "-d", gateway + "/32", "-j", "MASQUERADE"},
+	})
+	if err != nil {
+		return err
+	}
+	// Note: The host might drop forwarded traffic by default.
+	// E.g. Docker sets the policy of FORWARD chain to DROP.
+	err = ensureChain("filter", forwardChain, [][]string{
+		{"-o", vethName, "-d", gateway + "/32", "-j", "ACCEPT"},
+		{"-i", vethName, "-s", gateway + "/32", "-j", "ACCEPT"},
+	})
+	if err != nil {
+		return err
+	}
+	// Note: The traffic from the MidoNet network to Node addresses
+	// is dispatched to NodePort Services there.
+	err = ensureJump("nat", "PREROUTING", []string{"-m", "addrtype", "--dst-type", "LOCAL", "!", "-i", vethName, "-j", nodePortsChain})
+	if err != nil {
+		return err
+	}
+	err = ensureJump("nat", "POSTROUTING", []string{"-j", postroutingChain})
+	if err != nil {
+		return err
+	}
+	return ensureJump("filter", "FORWARD", []string{"-j", forwardChain})
+}
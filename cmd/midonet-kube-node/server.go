@@ -28,6 +28,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/health"
 	"github.com/midonet/midonet-kubernetes/pkg/k8s"
 	api "github.com/midonet/midonet-kubernetes/pkg/nodeapi"
 )
@@ -92,7 +93,7 @@ func (s *server) DeletePodAnnotation(ctx context.Context, in *api.DeletePodAnnot
 	}, nil
 }
 
-func serveRPC(clientset *kubernetes.Clientset) {
+func serveRPC(clientset *kubernetes.Clientset, started *health.Flag) {
 	log.Info("Starting RPC server")
 	logger := log.WithField("path", api.Path)
 	os.Remove(api.Path)
@@ -104,6 +105,7 @@ func serveRPC(clientset *kubernetes.Clientset) {
 	s := grpc.NewServer()
 	api.RegisterMidoNetKubeNodeServer(s, &server{client: clientset})
 	logger.Info("Serving")
+	started.Set()
 	err = s.Serve(l)
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to serve")
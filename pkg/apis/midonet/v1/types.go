@@ -21,7 +21,6 @@ import (
 
 // Translation is an ordered set of BackendResources.
 // +genclient
-// +genclient:noStatus
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type Translation struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -29,11 +28,49 @@ type Translation struct {
 
 	Resources []BackendResource `json:"resources"`
 
-	// REVISIT: We can have a status field to track dirty/in-sync status
-	// of the Translation with regard to the backend.  That way we can
-	// reduce the amount of backend calls on a reboot of the pusher
-	// controller.  On the other hand, it would increase the number of
-	// RPCs in the normal operations to update the field.
+	// Status is written by the pusher controller.
+	// Note: It costs an extra RPC for each push.  In exchange, the pusher
+	// can skip in-sync Translations on a restart.
+	Status TranslationStatus `json:"status,omitempty"`
+}
+
+// TranslationPhase describes the sync status of a Translation or
+// a BackendResource with regard to the backend.
+type TranslationPhase string
+
+const (
+	// TranslationPending means that the resources have not been
+	// pushed yet.
+	TranslationPending TranslationPhase = "Pending"
+
+	// TranslationSynced means that the resources have been pushed.
+	TranslationSynced TranslationPhase = "Synced"
+
+	// TranslationError means that the last attempt to push or delete
+	// the resources failed.
+	TranslationError TranslationPhase = "Error"
+)
+
+// TranslationStatus is the sync status of a Translation.
+type TranslationStatus struct {
+	// ObservedGeneration is the generation of the Translation which
+	// the pusher processed last.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	Phase        TranslationPhase `json:"phase,omitempty"`
+	LastError    string           `json:"lastError,omitempty"`
+	LastSyncTime *metav1.Time     `json:"lastSyncTime,omitempty"`
+
+	// Resources has the push result of each BackendResource, in the
+	// same order as Translation.Resources.
+	Resources []BackendResourceStatus `json:"resources,omitempty"`
+}
+
+// BackendResourceStatus is the push result of a BackendResource.
+type BackendResourceStatus struct {
+	Kind  string           `json:"kind"`
+	Phase TranslationPhase `json:"phase"`
+	Error string           `json:"error,omitempty"`
 }
 
 // TranslationList is a list of Translations.
@@ -40,6 +40,22 @@ func (in *BackendResource) DeepCopy() *BackendResource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackendResourceStatus) DeepCopyInto(out *BackendResourceStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackendResourceStatus.
+func (in *BackendResourceStatus) DeepCopy() *BackendResourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackendResourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Translation) DeepCopyInto(out *Translation) {
 	*out = *in
@@ -50,6 +66,7 @@ func (in *Translation) DeepCopyInto(out *Translation) {
 		*out = make([]BackendResource, len(*in))
 		copy(*out, *in)
 	}
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -103,3 +120,28 @@ func (in *TranslationList) DeepCopyObject() runtime.Object {
 	}
 	return nil
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TranslationStatus) DeepCopyInto(out *TranslationStatus) {
+	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]BackendResourceStatus, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TranslationStatus.
+func (in *TranslationStatus) DeepCopy() *TranslationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TranslationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
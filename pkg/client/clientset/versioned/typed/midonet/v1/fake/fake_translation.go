@@ -100,6 +100,18 @@ func (c *FakeTranslations) Update(translation *midonet_v1.Translation) (result *
 	return obj.(*midonet_v1.Translation), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeTranslations) UpdateStatus(translation *midonet_v1.Translation) (*midonet_v1.Translation, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(translationsResource, "status", c.ns, translation), &midonet_v1.Translation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*midonet_v1.Translation), err
+}
+
 // Delete takes name of the translation and deletes it. Returns an error if one occurs.
 func (c *FakeTranslations) Delete(name string, options *v1.DeleteOptions) error {
 	_, err := c.Fake.
@@ -37,6 +37,7 @@ type TranslationsGetter interface {
 type TranslationInterface interface {
 	Create(*v1.Translation) (*v1.Translation, error)
 	Update(*v1.Translation) (*v1.Translation, error)
+	UpdateStatus(*v1.Translation) (*v1.Translation, error)
 	Delete(name string, options *meta_v1.DeleteOptions) error
 	DeleteCollection(options *meta_v1.DeleteOptions, listOptions meta_v1.ListOptions) error
 	Get(name string, options meta_v1.GetOptions) (*v1.Translation, error)
@@ -120,6 +121,22 @@ func (c *translations) Update(translation *v1.Translation) (result *v1.Translati
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+
+func (c *translations) UpdateStatus(translation *v1.Translation) (result *v1.Translation, err error) {
+	result = &v1.Translation{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("translations").
+		Name(translation.Name).
+		SubResource("status").
+		Body(translation).
+		Do().
+		Into(result)
+	return
+}
+
 // Delete takes name of the translation and deletes it. Returns an error if one occurs.
 func (c *translations) Delete(name string, options *meta_v1.DeleteOptions) error {
 	return c.client.Delete().
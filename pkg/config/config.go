@@ -15,31 +15,157 @@
 package config
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/kelseyhightower/envconfig"
 )
 
 // Config is an envconfig definition for midonet-kube-controllers.
 type Config struct {
+	// Optional configuration file which overrides the environment
+	// variables.  See doc/configuration.md.
+	ConfigFile              string        `split_words:"true" default:""`
+	ConfigFileCheckInterval time.Duration `split_words:"true" default:"10s"`
+
 	// Minimum log level to emit.
 	LogLevel string `default:"info" split_words:"true"`
 
 	// Which controllers to run.
-	EnabledControllers string `default:"node,pod,service,endpoints,pusher,nodeannotator" split_words:"true"`
+	EnabledControllers string `default:"node,pod,service,endpoints,networkpolicy,pusher,nodeannotator" split_words:"true"`
+
+	// The number of workers of each controller.  A comma separated list
+	// of controller=number.  e.g. "pusher=4,pod=4"  Unlisted ones have 1.
+	ControllerWorkers string `default:"" split_words:"true"`
 
 	// Path to a kubeconfig file to use for accessing the k8s API.
 	Kubeconfig string `default:"" split_words:"false"`
 
+	// Leader election among replicas.  Only the leader runs
+	// the controllers.  See doc/controllers.md.
+	LeaderElect              bool          `split_words:"true" default:"false"`
+	LeaderElectLockType      string        `split_words:"true" default:"configmaps"`
+	LeaderElectNamespace     string        `split_words:"true" default:"kube-system"`
+	LeaderElectName          string        `split_words:"true" default:"midonet-kube-controllers"`
+	LeaderElectLeaseDuration time.Duration `split_words:"true" default:"15s"`
+	LeaderElectRenewDeadline time.Duration `split_words:"true" default:"10s"`
+	LeaderElectRetryPeriod   time.Duration `split_words:"true" default:"2s"`
+
+	// How long to wait for in-flight items to finish on SIGTERM.
+	// It should be shorter than terminationGracePeriodSeconds.
+	ShutdownTimeout time.Duration `split_words:"true" default:"20s"`
+
 	// MidoNet API URL and credential
 	MidoNetAPI      string `envconfig:"midonet_api" default:"https://localhost:8181/midonet-api"`
 	MidoNetUserName string `envconfig:"midonet_username" default:"admin"`
 	MidoNetPassword string `envconfig:"midonet_password" default:""`
 	MidoNetProject  string `envconfig:"midonet_project" default:""`
 
+	// How to authenticate to MidoNet API.  One of "midonet" (login to
+	// MidoNet API with the above credential), "keystone" (obtain a token
+	// from Keystone) and "token" (use MidoNetToken as it is).
+	MidoNetAuth  string `envconfig:"midonet_auth" default:"midonet"`
+	MidoNetToken string `envconfig:"midonet_token" default:""`
+
+	// Keystone v3 settings for "keystone" MidoNetAuth.  If an application
+	// credential is specified, it's used instead of MidoNetUserName and
+	// MidoNetPassword.
+	KeystoneURL                         string `envconfig:"keystone_url" default:""`
+	KeystoneUserDomain                  string `envconfig:"keystone_user_domain" default:"Default"`
+	KeystoneProjectDomain               string `envconfig:"keystone_project_domain" default:"Default"`
+	KeystoneApplicationCredentialID     string `envconfig:"keystone_application_credential_id" default:""`
+	KeystoneApplicationCredentialSecret string `envconfig:"keystone_application_credential_secret" default:""`
+
+	// TLS settings for MidoNet API.  The files can be mounted from
+	// a Secret.  See doc/configuration.md.
+	MidoNetCAFile             string `envconfig:"midonet_ca_file" default:""`
+	MidoNetCertFile           string `envconfig:"midonet_cert_file" default:""`
+	MidoNetKeyFile            string `envconfig:"midonet_key_file" default:""`
+	MidoNetServerName         string `envconfig:"midonet_server_name" default:""`
+	MidoNetInsecureSkipVerify bool   `envconfig:"midonet_insecure_skip_verify" default:"false"`
+
+	// Compare resources with the ones on MidoNet API and skip writes
+	// of unchanged resources.  It costs a GET for each resource pushed.
+	MidoNetSkipNoopUpdates bool `envconfig:"midonet_skip_noop_updates" default:"false"`
+
+	// Timeouts for MidoNet API.  0 means no timeout.
+	MidoNetConnectTimeout time.Duration `envconfig:"midonet_connect_timeout" default:"10s"`
+	MidoNetRequestTimeout time.Duration `envconfig:"midonet_request_timeout" default:"60s"`
+
 	// MidoNet tenantId to group resources maintained by our controllers
 	Tenant string `default:"midonetkube"`
+
+	// The port range for SNAT rules.
+	NATPortFrom int `envconfig:"nat_port_from" default:"30000"`
+	NATPortTo   int `envconfig:"nat_port_to" default:"60000"`
+
+	// Comma separated list of IPv4 CIDRs and ranges to allocate
+	// LoadBalancer IPs from.  Used by the loadbalancer controller.
+	LoadBalancerIPPool string `envconfig:"loadbalancer_ip_pool" default:""`
+
+	// The Pod network.  Required for the uplink.  Also used to distribute
+	// Pods among endpoints for ClientIP session affinity.
+	ClusterCIDR string `default:"" split_words:"false"`
+
+	// Optional uplink for the external connectivity.  See doc/uplink.md.
+	UplinkAddress          string `split_words:"true" default:""`
+	UplinkGateway          string `split_words:"true" default:""`
+	UplinkHostID           string `envconfig:"uplink_host_id" default:""`
+	UplinkInterface        string `split_words:"true" default:""`
+	UplinkProviderRouterID string `envconfig:"uplink_provider_router_id" default:""`
+	UplinkNetworks         string `split_words:"true" default:""`
+	UplinkBGPLocalAS       int    `envconfig:"uplink_bgp_local_as" default:"0"`
+	UplinkBGPPeerAS        int    `envconfig:"uplink_bgp_peer_as" default:"0"`
+	UplinkBGPPeerAddress   string `envconfig:"uplink_bgp_peer_address" default:""`
+
+	// Whether the pusher lists MidoNet resources owned by Tenant at once
+	// on startup and skips pushes of Translations already on MidoNet.
+	BulkResync bool `split_words:"true" default:"false"`
+
+	// How often the pusher compares Synced Translations with MidoNet.
+	// 0 disables the check.
+	DriftCheckInterval time.Duration `split_words:"true" default:"0"`
+
+	// Whether the pusher repairs the drift it found.
+	DriftRepair bool `split_words:"true" default:"false"`
+
+	// How often the pusher looks for MidoNet resources owned by Tenant
+	// but not in any Translations.  0 disables the garbage collection.
+	GCInterval time.Duration `envconfig:"gc_interval" default:"0"`
+
+	// Only report the garbage without deleting it.
+	GCDryRun bool `envconfig:"gc_dry_run" default:"true"`
+
+	// Don't delete anything if more than this percent of the resources
+	// owned by Tenant are garbage.  It's likely a misconfiguration.
+	GCMaxDeletePercent int `envconfig:"gc_max_delete_percent" default:"10"`
 }
 
 // Parse parses envconfig and stores in Config struct
 func (c *Config) Parse() error {
 	return envconfig.Process("midonetkube", c)
 }
+
+const redacted = "REDACTED"
+
+func redact(s *string) {
+	if *s != "" {
+		*s = redacted
+	}
+}
+
+// Redacted returns a copy of the Config with the secrets masked.
+func (c *Config) Redacted() *Config {
+	r := *c
+	redact(&r.MidoNetPassword)
+	redact(&r.MidoNetToken)
+	redact(&r.KeystoneApplicationCredentialSecret)
+	return &r
+}
+
+// String implements fmt.Stringer so that the secrets are not logged.
+func (c *Config) String() string {
+	// Use a type without the method to avoid the recursion.
+	type plainConfig Config
+	return fmt.Sprintf("%+v", plainConfig(*c.Redacted()))
+}
@@ -0,0 +1,45 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRedacted(t *testing.T) {
+	c := defaultConfig(t)
+	c.MidoNetPassword = "password-value"
+	c.MidoNetToken = "token-value"
+	c.KeystoneApplicationCredentialSecret = "secret-value"
+	for _, s := range []string{c.String(), fmt.Sprint(c), fmt.Sprintf("%v", c.Redacted())} {
+		for _, secret := range []string{"password-value", "token-value", "secret-value"} {
+			if strings.Contains(s, secret) {
+				t.Errorf("%q leaked in %s", secret, s)
+			}
+		}
+		if !strings.Contains(s, "MidoNetPassword:REDACTED") {
+			t.Errorf("Password not redacted in %s", s)
+		}
+	}
+	if c.MidoNetPassword != "password-value" {
+		t.Errorf("Redacted modified the Config")
+	}
+	if r := new(Config).Redacted(); r.MidoNetToken != "" {
+		t.Errorf("Empty token redacted: %q", r.MidoNetToken)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+// File is the structure of the configuration file.  (YAML or JSON)
+// Fields in the file override the corresponding environment variables.
+// Omitted fields keep the values from the environment.
+// See doc/configuration.md.
+type File struct {
+	LogLevel           *string        `json:"logLevel,omitempty"`
+	EnabledControllers []string       `json:"enabledControllers,omitempty"`
+	ControllerWorkers  map[string]int `json:"controllerWorkers,omitempty"`
+	MidoNet            *MidoNetFile   `json:"midonet,omitempty"`
+	Tenant             *string        `json:"tenant,omitempty"`
+	ClusterCIDR        *string        `json:"clusterCIDR,omitempty"`
+	LoadBalancerIPPool *string        `json:"loadBalancerIPPool,omitempty"`
+	NAT                *NATFile       `json:"nat,omitempty"`
+	BulkResync         *bool          `json:"bulkResync,omitempty"`
+	Drift              *DriftFile     `json:"drift,omitempty"`
+	GC                 *GCFile        `json:"gc,omitempty"`
+	Keystone           *KeystoneFile  `json:"keystone,omitempty"`
+}
+
+// MidoNetFile is the MidoNet API section of File.
+type MidoNetFile struct {
+	API                *string   `json:"api,omitempty"`
+	UserName           *string   `json:"username,omitempty"`
+	Password           *string   `json:"password,omitempty"`
+	Project            *string   `json:"project,omitempty"`
+	Auth               *string   `json:"auth,omitempty"`
+	Token              *string   `json:"token,omitempty"`
+	CAFile             *string   `json:"caFile,omitempty"`
+	CertFile           *string   `json:"certFile,omitempty"`
+	KeyFile            *string   `json:"keyFile,omitempty"`
+	ServerName         *string   `json:"serverName,omitempty"`
+	InsecureSkipVerify *bool     `json:"insecureSkipVerify,omitempty"`
+	ConnectTimeout     *Duration `json:"connectTimeout,omitempty"`
+	RequestTimeout     *Duration `json:"requestTimeout,omitempty"`
+	SkipNoopUpdates    *bool     `json:"skipNoopUpdates,omitempty"`
+}
+
+// KeystoneFile is the Keystone section of File.
+type KeystoneFile struct {
+	URL                         *string `json:"url,omitempty"`
+	UserDomain                  *string `json:"userDomain,omitempty"`
+	ProjectDomain               *string `json:"projectDomain,omitempty"`
+	ApplicationCredentialID     *string `json:"applicationCredentialID,omitempty"`
+	ApplicationCredentialSecret *string `json:"applicationCredentialSecret,omitempty"`
+}
+
+// NATFile is the NAT section of File.
+type NATFile struct {
+	PortFrom *int `json:"portFrom,omitempty"`
+	PortTo   *int `json:"portTo,omitempty"`
+}
+
+// DriftFile is the drift detection section of File.
+type DriftFile struct {
+	CheckInterval *Duration `json:"checkInterval,omitempty"`
+	Repair        *bool     `json:"repair,omitempty"`
+}
+
+// GCFile is the garbage collection section of File.
+type GCFile struct {
+	Interval         *Duration `json:"interval,omitempty"`
+	DryRun           *bool     `json:"dryRun,omitempty"`
+	MaxDeletePercent *int      `json:"maxDeletePercent,omitempty"`
+}
+
+// Duration is a time.Duration written like "10m" in the file.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// Load reads the environment variables and then the configuration file,
+// if any, and validates the result.
+func Load() (*Config, error) {
+	c := new(Config)
+	if err := c.Parse(); err != nil {
+		return nil, err
+	}
+	if c.ConfigFile != "" {
+		if err := c.LoadFile(c.ConfigFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadFile overrides Config with the given configuration file.
+// A missing file is not an error.  It's treated as an empty file,
+// so that the file can be added to the ConfigMap later.
+func (c *Config) LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f, err := parseFile(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	c.apply(f)
+	return nil
+}
+
+func parseFile(data []byte) (*File, error) {
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{}
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func setString(dst *string, src *string) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+func setInt(dst *int, src *int) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+func setBool(dst *bool, src *bool) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+func setDuration(dst *time.Duration, src *Duration) {
+	if src != nil {
+		*dst = src.Duration
+	}
+}
+
+func (c *Config) apply(f *File) {
+	setString(&c.LogLevel, f.LogLevel)
+	if f.EnabledControllers != nil {
+		c.EnabledControllers = strings.Join(f.EnabledControllers, ",")
+	}
+	if f.ControllerWorkers != nil {
+		var workers []string
+		for name, n := range f.ControllerWorkers {
+			workers = append(workers, fmt.Sprintf("%s=%d", name, n))
+		}
+		sort.Strings(workers)
+		c.ControllerWorkers = strings.Join(workers, ",")
+	}
+	if f.MidoNet != nil {
+		setString(&c.MidoNetAPI, f.MidoNet.API)
+		setString(&c.MidoNetUserName, f.MidoNet.UserName)
+		setString(&c.MidoNetPassword, f.MidoNet.Password)
+		setString(&c.MidoNetProject, f.MidoNet.Project)
+		setString(&c.MidoNetAuth, f.MidoNet.Auth)
+		setString(&c.MidoNetToken, f.MidoNet.Token)
+		setString(&c.MidoNetCAFile, f.MidoNet.CAFile)
+		setString(&c.MidoNetCertFile, f.MidoNet.CertFile)
+		setString(&c.MidoNetKeyFile, f.MidoNet.KeyFile)
+		setString(&c.MidoNetServerName, f.MidoNet.ServerName)
+		setBool(&c.MidoNetInsecureSkipVerify, f.MidoNet.InsecureSkipVerify)
+		setDuration(&c.MidoNetConnectTimeout, f.MidoNet.ConnectTimeout)
+		setDuration(&c.MidoNetRequestTimeout, f.MidoNet.RequestTimeout)
+		setBool(&c.MidoNetSkipNoopUpdates, f.MidoNet.SkipNoopUpdates)
+	}
+	if f.Keystone != nil {
+		setString(&c.KeystoneURL, f.Keystone.URL)
+		setString(&c.KeystoneUserDomain, f.Keystone.UserDomain)
+		setString(&c.KeystoneProjectDomain, f.Keystone.ProjectDomain)
+		setString(&c.KeystoneApplicationCredentialID, f.Keystone.ApplicationCredentialID)
+		setString(&c.KeystoneApplicationCredentialSecret, f.Keystone.ApplicationCredentialSecret)
+	}
+	setString(&c.Tenant, f.Tenant)
+	setString(&c.ClusterCIDR, f.ClusterCIDR)
+	setString(&c.LoadBalancerIPPool, f.LoadBalancerIPPool)
+	if f.NAT != nil {
+		setInt(&c.NATPortFrom, f.NAT.PortFrom)
+		setInt(&c.NATPortTo, f.NAT.PortTo)
+	}
+	setBool(&c.BulkResync, f.BulkResync)
+	if f.Drift != nil {
+		setDuration(&c.DriftCheckInterval, f.Drift.CheckInterval)
+		setBool(&c.DriftRepair, f.Drift.Repair)
+	}
+	if f.GC != nil {
+		setDuration(&c.GCInterval, f.GC.Interval)
+		setBool(&c.GCDryRun, f.GC.DryRun)
+		setInt(&c.GCMaxDeletePercent, f.GC.MaxDeletePercent)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func defaultConfig(t *testing.T) *Config {
+	c := new(Config)
+	if err := c.Parse(); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return c
+}
+
+func writeFile(t *testing.T, data string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	path := filepath.Join(dir, "controllers.yaml")
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	path := writeFile(t, `
+enabledControllers: [pod, pusher]
+controllerWorkers:
+  pusher: 4
+  pod: 2
+midonet:
+  api: http://192.0.2.1:8181/midonet-api
+nat:
+  portFrom: 40000
+gc:
+  interval: 1h
+  dryRun: false
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+	c := defaultConfig(t)
+	if err := c.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	expected := defaultConfig(t)
+	expected.EnabledControllers = "pod,pusher"
+	expected.ControllerWorkers = "pod=2,pusher=4"
+	expected.MidoNetAPI = "http://192.0.2.1:8181/midonet-api"
+	expected.NATPortFrom = 40000
+	expected.GCInterval = time.Hour
+	expected.GCDryRun = false
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("got %+v\nwant %+v", c, expected)
+	}
+
+	// A missing file is an empty file
+	c = defaultConfig(t)
+	if err := c.LoadFile(path + ".missing"); err != nil {
+		t.Errorf("LoadFile: %v", err)
+	}
+	if !reflect.DeepEqual(c, defaultConfig(t)) {
+		t.Errorf("got %+v\nwant defaults", c)
+	}
+}
+
+func TestLoadFileInvalid(t *testing.T) {
+	for _, data := range []string{
+		"unknownField: 1",
+		"gc: {interval: 1}",
+		"nat: {portFrom: 70000}",
+		"enabledControllers: [pod, unknown]",
+		"controllerWorkers: {pusher: 0}",
+		"midonet: {api: localhost:8181}",
+		"midonet: {certFile: /tls/tls.crt}",
+		"midonet: {auth: unknown}",
+		"midonet: {auth: token}",
+		"midonet: {auth: keystone}",
+		"{midonet: {auth: keystone}, keystone: {url: 'http://localhost:5000/v3', applicationCredentialID: id}}",
+	} {
+		path := writeFile(t, data)
+		c := defaultConfig(t)
+		err := c.LoadFile(path)
+		if err == nil {
+			err = c.Validate()
+		}
+		if err == nil {
+			t.Errorf("%q: got nil\nwant an error", data)
+		}
+		os.RemoveAll(filepath.Dir(path))
+	}
+}
+
+func TestNonReloadableChanges(t *testing.T) {
+	old := defaultConfig(t)
+	new := defaultConfig(t)
+	new.LogLevel = "debug"
+	new.GCDryRun = !old.GCDryRun
+	if changed := NonReloadableChanges(old, new); changed != nil {
+		t.Errorf("got %v\nwant nil", changed)
+	}
+	new.Tenant = "changed"
+	new.NATPortTo = 50000
+	expected := []string{"Tenant", "NATPortTo"}
+	if changed := NonReloadableChanges(old, new); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("got %v\nwant %v", changed, expected)
+	}
+}
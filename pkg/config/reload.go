@@ -0,0 +1,99 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ReloadableFields are the fields of Config which take effect without
+// a restart.
+var ReloadableFields = []string{
+	"LogLevel",
+	"DriftRepair",
+	"GCDryRun",
+	"GCMaxDeletePercent",
+}
+
+func isReloadable(name string) bool {
+	for _, f := range ReloadableFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// NonReloadableChanges returns the names of the fields which differ
+// between the given Configs and need a restart to take effect.
+func NonReloadableChanges(old, new *Config) []string {
+	var changed []string
+	ov := reflect.ValueOf(old).Elem()
+	nv := reflect.ValueOf(new).Elem()
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Name
+		if isReloadable(name) {
+			continue
+		}
+		if !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// Watch polls the configuration file until the stop channel is closed.
+// When the file changed, it loads the configuration again and calls
+// onChange with the result.  An invalid configuration is logged and
+// ignored.
+// Note: Polling rather than inotify because ConfigMap volumes are
+// updated by replacing a symlink.
+func Watch(path string, interval time.Duration, stop <-chan struct{}, onChange func(*Config)) {
+	clog := log.WithField("path", path)
+	last, _ := ioutil.ReadFile(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			clog.WithError(err).Warn("Failed to read the configuration file")
+			continue
+		}
+		if bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		c, err := Load()
+		if err != nil {
+			clog.WithError(err).Error("Ignoring the invalid configuration")
+			continue
+		}
+		clog.Info("Configuration file changed")
+		onChange(c)
+	}
+}
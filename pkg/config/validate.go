@@ -0,0 +1,139 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Controllers is the list of the known controllers.
+var Controllers = []string{
+	"node",
+	"pod",
+	"service",
+	"endpoints",
+	"networkpolicy",
+	"pusher",
+	"nodeannotator",
+	"loadbalancer",
+}
+
+func isController(name string) bool {
+	for _, c := range Controllers {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseWorkers parses a string like "pusher=4,pod=4".
+func ParseWorkers(s string) (map[string]int, error) {
+	workers := make(map[string]int)
+	if s == "" {
+		return workers, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("Invalid worker count %s", kv)
+		}
+		n, err := strconv.Atoi(pair[1])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("Invalid worker count %s", kv)
+		}
+		workers[pair[0]] = n
+	}
+	return workers, nil
+}
+
+// Validate checks the values which are easy to get wrong.
+// Note: The uplink is validated by the converter package.
+func (c *Config) Validate() error {
+	if _, err := log.ParseLevel(c.LogLevel); err != nil {
+		return err
+	}
+	for _, name := range strings.Split(c.EnabledControllers, ",") {
+		if !isController(name) {
+			return fmt.Errorf("Unknown controller %q", name)
+		}
+	}
+	workers, err := ParseWorkers(c.ControllerWorkers)
+	if err != nil {
+		return err
+	}
+	for name := range workers {
+		if !isController(name) {
+			return fmt.Errorf("Unknown controller %q in ControllerWorkers", name)
+		}
+	}
+	u, err := url.Parse(c.MidoNetAPI)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("Invalid MidoNet API URL %q", c.MidoNetAPI)
+	}
+	if (c.MidoNetCertFile == "") != (c.MidoNetKeyFile == "") {
+		return fmt.Errorf("MidoNet client certificate and key should be specified together")
+	}
+	switch c.MidoNetAuth {
+	case "midonet":
+	case "keystone":
+		if c.KeystoneURL == "" {
+			return fmt.Errorf("Keystone URL is required for keystone auth")
+		}
+		if (c.KeystoneApplicationCredentialID == "") != (c.KeystoneApplicationCredentialSecret == "") {
+			return fmt.Errorf("Keystone application credential ID and secret should be specified together")
+		}
+		if c.KeystoneApplicationCredentialID == "" && c.MidoNetUserName == "" {
+			return fmt.Errorf("Keystone auth requires either an application credential or a username")
+		}
+	case "token":
+		if c.MidoNetToken == "" {
+			return fmt.Errorf("MidoNet token is required for token auth")
+		}
+	default:
+		return fmt.Errorf("Unknown MidoNet auth %q", c.MidoNetAuth)
+	}
+	if c.MidoNetConnectTimeout < 0 || c.MidoNetRequestTimeout < 0 {
+		return fmt.Errorf("Negative MidoNet API timeout")
+	}
+	if c.Tenant == "" {
+		return fmt.Errorf("Empty Tenant")
+	}
+	if c.ClusterCIDR != "" {
+		if _, _, err := net.ParseCIDR(c.ClusterCIDR); err != nil {
+			return err
+		}
+	}
+	if c.NATPortFrom < 1 || c.NATPortFrom > c.NATPortTo || c.NATPortTo > 65535 {
+		return fmt.Errorf("Invalid NAT port range %d-%d", c.NATPortFrom, c.NATPortTo)
+	}
+	if c.DriftCheckInterval < 0 || c.GCInterval < 0 {
+		return fmt.Errorf("Negative interval")
+	}
+	if c.GCMaxDeletePercent < 0 || c.GCMaxDeletePercent > 100 {
+		return fmt.Errorf("Invalid GCMaxDeletePercent %d", c.GCMaxDeletePercent)
+	}
+	return nil
+}
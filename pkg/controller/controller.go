@@ -16,6 +16,14 @@
 package controller
 
 import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -23,50 +31,212 @@ import (
 	"k8s.io/client-go/util/workqueue"
 )
 
+var (
+	workerBusySeconds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "midonet_kube_controllers",
+			Subsystem: "controller",
+			Name:      "worker_busy_seconds_total",
+			Help:      "Time spent by each worker processing items",
+		},
+		[]string{"controller", "worker"},
+	)
+
+	workerItems = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "midonet_kube_controllers",
+			Subsystem: "controller",
+			Name:      "worker_items_total",
+			Help:      "Number of items processed by each worker",
+		},
+		[]string{"controller", "worker", "result"},
+	)
+
+	processingLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "midonet_kube_controllers",
+			Subsystem: "controller",
+			Name:      "processing_duration_seconds",
+			Help:      "Time taken to process an item",
+		},
+		[]string{"controller", "result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(workerBusySeconds)
+	prometheus.MustRegister(workerItems)
+	prometheus.MustRegister(processingLatency)
+}
+
 // Handler is a set of callbacks to process events on the queue.
 type Handler interface {
 	Update(string, schema.GroupVersionKind, interface{}) error
 	Delete(string) error
 }
 
+// ShardFunc returns the shard of the given key.  The items in the same
+// shard are processed one by one, in the queued order.
+type ShardFunc func(key string) string
+
 // Controller describes a controller to watch the given GVK events.
 type Controller struct {
 	informer cache.SharedIndexInformer
 	queue    workqueue.RateLimitingInterface
 	handler  Handler
 	gvk      schema.GroupVersionKind
+	tasks    []func(<-chan struct{})
+	workers  int
+	shard    ShardFunc
+	ctx      context.Context
+	cancel   context.CancelFunc
 }
 
 // NewController creates a controller.
 func NewController(gvk schema.GroupVersionKind, informer cache.SharedIndexInformer, handler Handler) *Controller {
-	queue := AddHandler(informer, gvk.String())
+	queue := newInstrumentedQueue(gvk.String(), gvk.Kind, "main")
+	informer.AddEventHandler(NewEventHandler(gvk.String(), queue))
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Controller{
 		informer: informer,
 		queue:    queue,
 		handler:  handler,
 		gvk:      gvk,
+		workers:  1,
+		shard:    func(key string) string { return key },
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 
-// Run executes the controller.
-func (c *Controller) Run() {
-	for c.processNextItem() {
+// SetWorkers sets the number of workers to process items concurrently.
+// It should be called before Run.
+func (c *Controller) SetWorkers(workers int) {
+	if workers < 1 {
+		workers = 1
 	}
+	c.workers = workers
+}
+
+// SetShardFunc sets the function to decide which items should not be
+// processed concurrently.  By default, every key is its own shard.
+// It should be called before Run.
+func (c *Controller) SetShardFunc(shard ShardFunc) {
+	c.shard = shard
+}
+
+// Context returns a context which is canceled by Cancel.  Handlers can
+// use it to interrupt slow backend operations on shutdown.
+func (c *Controller) Context() context.Context {
+	return c.ctx
+}
+
+// Cancel cancels the context returned by Context.  It's meant to be
+// called when the controller didn't stop within the shutdown timeout,
+// so that handlers give up the items being processed.
+func (c *Controller) Cancel() {
+	c.cancel()
 }
 
-func (c *Controller) processNextItem() bool {
-	queue := c.queue
+// AddBackgroundTask registers a function to run in its own goroutine
+// when the controller starts running.
+// E.g. a periodic task which should run only on the leader.
+// The function should return when the given channel is closed.
+func (c *Controller) AddBackgroundTask(task func(stop <-chan struct{})) {
+	c.tasks = append(c.tasks, task)
+}
+
+// Run executes the controller until the stop channel is closed.
+// Items in the queue are dispatched to per-worker queues by their shards.
+// Note: A key is always dispatched to the same worker as long as its
+// shard doesn't change.  Thus a key is never processed concurrently.
+// When stopped, the queues are shut down and workers finish the items
+// being processed but don't take new ones.  Run returns after all
+// workers exited.  See Cancel to interrupt the items being processed.
+func (c *Controller) Run(stop <-chan struct{}) {
+	for _, task := range c.tasks {
+		go task(stop)
+	}
+	go func() {
+		<-stop
+		c.queue.ShutDown()
+	}()
+	var wg sync.WaitGroup
+	queues := make([]workqueue.RateLimitingInterface, c.workers)
+	for i := range queues {
+		queues[i] = newInstrumentedQueue(fmt.Sprintf("%s-%d", c.gvk.String(), i), c.gvk.Kind, strconv.Itoa(i))
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.runWorker(i, queues[i], stop)
+		}(i)
+	}
+	for c.dispatchNextItem(queues) {
+	}
+	wg.Wait()
+	log.WithField("controller", c.gvk.Kind).Info("Stopped")
+}
+
+func (c *Controller) dispatchNextItem(queues []workqueue.RateLimitingInterface) bool {
+	key, quit := c.queue.Get()
+	if quit {
+		for _, q := range queues {
+			q.ShutDown()
+		}
+		return false
+	}
+	defer c.queue.Done(key)
+	h := fnv.New32a()
+	h.Write([]byte(c.shard(key.(string))))
+	queues[h.Sum32()%uint32(len(queues))].Add(key)
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *Controller) runWorker(worker int, queue workqueue.RateLimitingInterface, stop <-chan struct{}) {
+	labels := prometheus.Labels{
+		"controller": c.gvk.Kind,
+		"worker":     strconv.Itoa(worker),
+	}
+	for c.processNextItem(queue, labels, stop) {
+	}
+}
+
+func (c *Controller) processNextItem(queue workqueue.RateLimitingInterface, labels prometheus.Labels, stop <-chan struct{}) bool {
 	key, quit := queue.Get()
 	if quit {
 		return false
 	}
 	defer queue.Done(key)
+	select {
+	case <-stop:
+		// Leave the remaining items to the next process.
+		return false
+	default:
+	}
 	clog := log.WithFields(log.Fields{
-		"key": key,
+		"key":    key,
+		"worker": labels["worker"],
 	})
 
 	clog.Debug("Start processing.")
+	startTime := time.Now()
 	err := c.processItem(key.(string), c.informer)
+	elapsed := time.Since(startTime).Seconds()
+	workerBusySeconds.With(labels).Add(elapsed)
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	processingLatency.With(prometheus.Labels{
+		"controller": labels["controller"],
+		"result":     result,
+	}).Observe(elapsed)
+	workerItems.With(prometheus.Labels{
+		"controller": labels["controller"],
+		"worker":     labels["worker"],
+		"result":     result,
+	}).Inc()
 	if err == nil {
 		clog.Debug("Done.")
 		queue.Forget(key)
@@ -0,0 +1,142 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/workqueue"
+)
+
+type countingHandler struct {
+	updates int
+	deletes int
+}
+
+func (h *countingHandler) Update(string, schema.GroupVersionKind, interface{}) error {
+	h.updates++
+	return nil
+}
+
+func (h *countingHandler) Delete(string) error {
+	h.deletes++
+	return nil
+}
+
+func TestDispatch(t *testing.T) {
+	c := &Controller{
+		queue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		shard: func(key string) string {
+			return strings.Split(key, "/")[0]
+		},
+	}
+	queues := make([]workqueue.RateLimitingInterface, 4)
+	for i := range queues {
+		queues[i] = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	}
+	keys := []string{"a/1", "b/1", "a/2", "c/1", "a/3", "b/2"}
+	for _, key := range keys {
+		c.queue.Add(key)
+	}
+	for range keys {
+		c.dispatchNextItem(queues)
+	}
+	// Items in the same shard are in the same queue, in the queued order.
+	shards := make(map[string]int)
+	last := make(map[string]string)
+	for i, q := range queues {
+		for q.Len() > 0 {
+			item, _ := q.Get()
+			key := item.(string)
+			shard := c.shard(key)
+			if j, ok := shards[shard]; ok && j != i {
+				t.Errorf("shard %s is in queues %d and %d", shard, i, j)
+			}
+			shards[shard] = i
+			if last[shard] > key {
+				t.Errorf("%s after %s", key, last[shard])
+			}
+			last[shard] = key
+		}
+	}
+	if len(shards) != 3 {
+		t.Errorf("got %d shards\nwant 3", len(shards))
+	}
+}
+
+func TestInstrumentedQueue(t *testing.T) {
+	q := newInstrumentedQueue("test", "Test", "main")
+	defer q.ShutDown()
+	q.Add("a")
+	q.Add("a")
+	if len(q.addTimes) != 1 {
+		t.Errorf("got %v\nwant a", q.addTimes)
+	}
+	item, _ := q.Get()
+	if item != "a" {
+		t.Errorf("got %v\nwant a", item)
+	}
+	if len(q.addTimes) != 0 {
+		t.Errorf("got %v\nwant nothing", q.addTimes)
+	}
+	q.Done(item)
+}
+
+func TestStop(t *testing.T) {
+	h := &countingHandler{}
+	c := &Controller{handler: h}
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	queue.Add("a/1")
+	queue.Add("a/2")
+	labels := prometheus.Labels{"controller": "Test", "worker": "0"}
+	stop := make(chan struct{})
+	close(stop)
+	if c.processNextItem(queue, labels, stop) {
+		t.Errorf("got true\nwant false")
+	}
+	if h.updates != 0 || h.deletes != 0 {
+		t.Errorf("processed an item after stop")
+	}
+}
+
+func TestStopKeepsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Controller{
+		queue:   workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		handler: &countingHandler{},
+		gvk:     schema.GroupVersionKind{Kind: "Test"},
+		workers: 2,
+		shard:   func(key string) string { return key },
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	stop := make(chan struct{})
+	close(stop)
+	c.Run(stop)
+	// In-flight operations are let to finish until Cancel.
+	if err := c.Context().Err(); err != nil {
+		t.Errorf("got %v after stop\nwant nil", err)
+	}
+	c.Cancel()
+	if c.Context().Err() == nil {
+		t.Errorf("got nil after Cancel\nwant an error")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+var (
+	queueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "midonet_kube_controllers",
+			Subsystem: "controller",
+			Name:      "queue_depth",
+			Help:      "Number of items waiting in the queue",
+		},
+		[]string{"controller", "queue"},
+	)
+
+	queueAdds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "midonet_kube_controllers",
+			Subsystem: "controller",
+			Name:      "queue_adds_total",
+			Help:      "Number of items added to the queue",
+		},
+		[]string{"controller", "queue"},
+	)
+
+	queueRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "midonet_kube_controllers",
+			Subsystem: "controller",
+			Name:      "queue_retries_total",
+			Help:      "Number of items added to the queue again for retries",
+		},
+		[]string{"controller", "queue"},
+	)
+
+	queueLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "midonet_kube_controllers",
+			Subsystem: "controller",
+			Name:      "queue_duration_seconds",
+			Help:      "Time an item waited in the queue before being processed",
+		},
+		[]string{"controller", "queue"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(queueDepth)
+	prometheus.MustRegister(queueAdds)
+	prometheus.MustRegister(queueRetries)
+	prometheus.MustRegister(queueLatency)
+}
+
+// instrumentedQueue is a RateLimitingInterface which maintains
+// the queue metrics.
+// Note: Items added by AddRateLimited and AddAfter are counted as
+// retries.  The time they wait in the queue is not observed because
+// they are added by the underlying queue asynchronously.
+type instrumentedQueue struct {
+	workqueue.RateLimitingInterface
+	labels prometheus.Labels
+
+	mu       sync.Mutex
+	addTimes map[interface{}]time.Time
+}
+
+func newInstrumentedQueue(name string, controller string, queue string) *instrumentedQueue {
+	rateLimiter := workqueue.DefaultControllerRateLimiter()
+	return &instrumentedQueue{
+		RateLimitingInterface: workqueue.NewNamedRateLimitingQueue(rateLimiter, name),
+		labels: prometheus.Labels{
+			"controller": controller,
+			"queue":      queue,
+		},
+		addTimes: make(map[interface{}]time.Time),
+	}
+}
+
+func (q *instrumentedQueue) Add(item interface{}) {
+	q.mu.Lock()
+	if _, ok := q.addTimes[item]; !ok {
+		q.addTimes[item] = time.Now()
+	}
+	q.mu.Unlock()
+	q.RateLimitingInterface.Add(item)
+	queueAdds.With(q.labels).Inc()
+	queueDepth.With(q.labels).Set(float64(q.Len()))
+}
+
+func (q *instrumentedQueue) AddAfter(item interface{}, duration time.Duration) {
+	q.RateLimitingInterface.AddAfter(item, duration)
+	queueRetries.With(q.labels).Inc()
+}
+
+func (q *instrumentedQueue) AddRateLimited(item interface{}) {
+	q.RateLimitingInterface.AddRateLimited(item)
+	queueRetries.With(q.labels).Inc()
+}
+
+func (q *instrumentedQueue) Get() (interface{}, bool) {
+	item, quit := q.RateLimitingInterface.Get()
+	queueDepth.With(q.labels).Set(float64(q.Len()))
+	if quit {
+		return item, quit
+	}
+	q.mu.Lock()
+	if t, ok := q.addTimes[item]; ok {
+		delete(q.addTimes, item)
+		queueLatency.With(q.labels).Observe(time.Since(t).Seconds())
+	}
+	q.mu.Unlock()
+	return item, quit
+}
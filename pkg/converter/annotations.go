@@ -29,4 +29,8 @@ const (
 
 	// MACAnnotation annotates MAC address for the Pod/Node.
 	MACAnnotation = "midonet.org/mac-address"
+
+	// UpdatedAtAnnotation annotates the time when the converter changed
+	// the resources of the Translation last time.  (RFC 3339)
+	UpdatedAtAnnotation = "midonet.org/updated-at"
 )
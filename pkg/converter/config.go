@@ -16,17 +16,95 @@
 package converter
 
 import (
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
 	"github.com/midonet/midonet-kubernetes/pkg/config"
 )
 
 // Config contains configuration for converter and its sub packages.
 type Config struct {
-	Tenant string
+	Tenant             string
+	LoadBalancerIPPool string
+
+	// The port range for SNAT rules.
+	NATPortFrom int
+	NATPortTo   int
+
+	// The Pod network.  nil if not configured.
+	ClusterCIDR *net.IPNet
+
+	// Uplink is nil unless the external connectivity is configured.
+	Uplink *UplinkConfig
+
+	// Used by the pusher controller.  See doc/custom-resource.md.
+	BulkResync         bool
+	DriftCheckInterval time.Duration
+	GCInterval         time.Duration
+
+	mu         sync.RWMutex
+	reloadable ReloadableConfig
+}
+
+// ReloadableConfig is the part of Config which can be changed while
+// the controllers are running.
+type ReloadableConfig struct {
+	// Used by the pusher controller.  See doc/custom-resource.md.
+	DriftRepair        bool
+	GCDryRun           bool
+	GCMaxDeletePercent int
+}
+
+// NewReloadableConfigFromEnvConfig creates ReloadableConfig from
+// envconfig instance.
+func NewReloadableConfigFromEnvConfig(config *config.Config) ReloadableConfig {
+	return ReloadableConfig{
+		DriftRepair:        config.DriftRepair,
+		GCDryRun:           config.GCDryRun,
+		GCMaxDeletePercent: config.GCMaxDeletePercent,
+	}
+}
+
+// Reloadable returns the current ReloadableConfig.
+func (c *Config) Reloadable() ReloadableConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.reloadable
+}
+
+// SetReloadable replaces ReloadableConfig.
+func (c *Config) SetReloadable(r ReloadableConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reloadable = r
 }
 
 // NewConfigFromEnvConfig creates Config from envconfig instance.
 func NewConfigFromEnvConfig(config *config.Config) *Config {
+	uplink, err := newUplinkConfig(config)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid uplink configuration")
+	}
+	var clusterCIDR *net.IPNet
+	if config.ClusterCIDR != "" {
+		_, clusterCIDR, err = net.ParseCIDR(config.ClusterCIDR)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid cluster CIDR")
+		}
+	}
 	return &Config{
-		Tenant: config.Tenant,
+		Tenant:             config.Tenant,
+		LoadBalancerIPPool: config.LoadBalancerIPPool,
+		NATPortFrom:        config.NATPortFrom,
+		NATPortTo:          config.NATPortTo,
+		ClusterCIDR:        clusterCIDR,
+		Uplink:             uplink,
+		BulkResync:         config.BulkResync,
+		DriftCheckInterval: config.DriftCheckInterval,
+		GCInterval:         config.GCInterval,
+		reloadable:         NewReloadableConfigFromEnvConfig(config),
 	}
 }
@@ -17,11 +17,13 @@ package endpoints
 
 import (
 	"fmt"
+	"net"
 
 	"k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/cache"
 
 	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/converter/service"
 )
 
 type endpointsConverter struct {
@@ -36,6 +38,11 @@ func endpoints(key string, svcIP string, subsets []v1.EndpointSubset) map[string
 	m := make(map[string][]endpoint, 0)
 	for _, s := range subsets {
 		for _, a := range s.Addresses {
+			ip := net.ParseIP(a.IP)
+			if ip == nil || ip.To4() == nil {
+				// REVISIT: IPv6 is not supported yet.
+				continue
+			}
 			for _, p := range s.Ports {
 				ep := endpoint{
 					endpointsKey: key,
@@ -67,24 +74,47 @@ func (c *endpointsConverter) Convert(key converter.Key, obj interface{}, config
 		return nil, nil, nil
 	}
 	svcSpec := svcObj.(*v1.Service).Spec
-	svcIP := svcSpec.ClusterIP
-	if svcSpec.Type != v1.ServiceTypeClusterIP || svcIP == "" || svcIP == v1.ClusterIPNone {
+	svcIP := service.ClusterIP(&svcSpec)
+	if svcIP == "" {
 		// Ignore Endpoints without ClusterIP.
 		return nil, nil, nil
 	}
+	affinity := clientIPAffinity(&svcSpec)
 	endpoint := obj.(*v1.Endpoints)
-	for _, eps := range endpoints(key.Key(), svcIP, endpoint.Subsets) {
+	for portName, eps := range endpoints(key.Key(), svcIP, endpoint.Subsets) {
 		for _, ep := range eps {
 			// We include almost everything in the key so that a modified
 			// endpoint is treated as another resource for the
 			// MidoNet side.  Note that MidoNet Chains and Rules are not
 			// updateable.
 			epKey := converter.Key{
-				Kind: "Endpoints-Port",
+				Kind: "Endpoints-Hairpin",
 				Name: fmt.Sprintf("%s/%s/%s/%s/%d/%s", key.Name, ep.portName, svcIP, ep.ip, ep.port, ep.protocol),
 			}
+			ep := ep
 			subs[epKey] = &ep
 		}
+		// A single DNAT Rule to distribute the traffic among all
+		// endpoints of the port.  Again, because Rules are not
+		// updateable, the key contains the hash of the set of
+		// the endpoints so that the Rule is re-created whenever
+		// the set changes.
+		lb := newEndpointsLB(key.Key(), portName, eps, affinity, config.ClusterCIDR)
+		lbKey := converter.Key{
+			Kind: "Endpoints-LB",
+			Name: fmt.Sprintf("%s/%s/%s/%s", key.Name, portName, svcIP, lb.hash()),
+		}
+		subs[lbKey] = lb
 	}
 	return resources, subs, nil
 }
+
+// clientIPAffinity returns true if the Service has ClientIP session
+// affinity.
+// Note: We map clients to endpoints statically.  (See affinityRules)
+// The mapping lasts as long as the set of endpoints is unchanged,
+// usually longer than sessionAffinityConfig.clientIP.timeoutSeconds,
+// which is thus ignored.
+func clientIPAffinity(svcSpec *v1.ServiceSpec) bool {
+	return svcSpec.SessionAffinity == v1.ServiceAffinityClientIP
+}
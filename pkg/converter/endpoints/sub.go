@@ -16,11 +16,18 @@
 package endpoints
 
 import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"sort"
 
+	"github.com/google/uuid"
 	"k8s.io/api/core/v1"
 
 	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/converter/service"
 	"github.com/midonet/midonet-kubernetes/pkg/midonet"
 )
 
@@ -43,52 +50,11 @@ func (ep *endpoint) Convert(epKey converter.Key, config *converter.Config) ([]co
 	// REVISIT: An assumption here is that, if ServicePort.Name is empty,
 	// the corresponding EndpointPort.Name is also empty.  It isn't clear
 	// to me (yamamoto) from the documentation.
-	portKey := ep.portKey()
-	portChainID := converter.IDForKey("ServicePort", portKey)
+	hairpinChainID := service.HairpinChainID(ep.portKey())
 	baseID := converter.IDForKey("Endpoint", epKey.Key())
-	epChainID := baseID
-	epJumpRuleID := converter.SubID(baseID, "Jump to Endpoint")
-	epDNATRuleID := converter.SubID(baseID, "DNAT")
-	epSNATRuleID := converter.SubID(baseID, "SNAT")
+	epSNATRuleID := converter.SubID(baseID, "Hairpin SNAT After DNAT")
 	return []converter.BackendResource{
-		&midonet.Chain{
-			ID:       &epChainID,
-			Name:     fmt.Sprintf("KUBE-SEP-%s", epKey.Key()),
-			TenantID: config.Tenant,
-		},
-		// REVISIT: kube-proxy implements load-balancing with its
-		// equivalent of this rule, using iptables probabilistic
-		// match.  We can probably implement something similar
-		// here if the backend has the following functionalities.
-		//
-		//   1. probabilistic match
-		//   2. applyIfExists equivalent
-		//
-		// For now, we just install a normal 100% matching rule.
-		// It means that the endpoint which happens to have its
-		// jump rule the earliest in the chain handles 100% of
-		// traffic.
-		&midonet.Rule{
-			Parent:      midonet.Parent{ID: &portChainID},
-			ID:          &epJumpRuleID,
-			Type:        "jump",
-			JumpChainID: &epChainID,
-		},
-		&midonet.Rule{
-			Parent: midonet.Parent{ID: &epChainID},
-			ID:     &epDNATRuleID,
-			Type:   "dnat",
-			NATTargets: &[]midonet.NATTarget{
-				{
-					AddressFrom: ep.ip,
-					AddressTo:   ep.ip,
-					PortFrom:    ep.port,
-					PortTo:      ep.port,
-				},
-			},
-			FlowAction: "accept",
-		},
-		// SNAT traffic from the endpoint itself. Otherwise,
+		// SNAT the traffic from the endpoint to itself.  Otherwise,
 		// the return traffic doesn't work.
 		// Note: Endpoint IP might or might not belong to the cluster ip
 		// range.  It can be external.
@@ -100,23 +66,191 @@ func (ep *endpoint) Convert(epKey converter.Key, config *converter.Config) ([]co
 		// this purpose.  It means that the source IP of the outgoing
 		// interface is chosen after an L3 routing decision.  With flannel,
 		// it would be the address of the cni0 interface on the node.
+		//
+		// Note: This Rule is evaluated after DNAT.  (See the service
+		// converter)  The traffic from the endpoint to the other
+		// endpoints keeps its source address.
 		&midonet.Rule{
-			Parent:       midonet.Parent{ID: &epChainID},
+			Parent:       midonet.Parent{ID: &hairpinChainID},
 			ID:           &epSNATRuleID,
 			Type:         "snat",
 			DLType:       0x800,
 			NWSrcAddress: ep.ip,
 			NWSrcLength:  32,
+			NWDstAddress: ep.ip,
+			NWDstLength:  32,
 			NATTargets: &[]midonet.NATTarget{
 				{
 					AddressFrom: ep.svcIP,
 					AddressTo:   ep.svcIP,
-					// REVISIT: arbitrary port range
-					PortFrom: 30000,
-					PortTo:   60000,
+					PortFrom:    config.NATPortFrom,
+					PortTo:      config.NATPortTo,
 				},
 			},
-			FlowAction: "continue",
+			FlowAction: "accept",
+		},
+	}, nil
+}
+
+// maxAffinityBuckets bounds the number of buckets per source address
+// range, and thus the number of DNAT Rules, for ClientIP session affinity.
+const maxAffinityBuckets = 32
+
+// endpointsLB is a pseudo resource to represent the set of endpoints
+// for a ServicePort.
+type endpointsLB struct {
+	endpointsKey string
+	portName     string
+	targets      []midonet.NATTarget
+	affinity     bool
+
+	// clusterCIDR is the Pod network, used to distribute Pods among
+	// endpoints for ClientIP session affinity.  It can be nil.
+	clusterCIDR *net.IPNet
+}
+
+func newEndpointsLB(endpointsKey string, portName string, eps []endpoint, affinity bool, clusterCIDR *net.IPNet) *endpointsLB {
+	targets := make([]midonet.NATTarget, 0, len(eps))
+	for _, ep := range eps {
+		targets = append(targets, midonet.NATTarget{
+			AddressFrom: ep.ip,
+			AddressTo:   ep.ip,
+			PortFrom:    ep.port,
+			PortTo:      ep.port,
+		})
+	}
+	// Sort the targets so that the same set of endpoints always
+	// produces the same hash regardless of the order in the subsets.
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].AddressFrom != targets[j].AddressFrom {
+			return targets[i].AddressFrom < targets[j].AddressFrom
+		}
+		return targets[i].PortFrom < targets[j].PortFrom
+	})
+	if clusterCIDR != nil && clusterCIDR.IP.To4() == nil {
+		clusterCIDR = nil
+	}
+	return &endpointsLB{
+		endpointsKey: endpointsKey,
+		portName:     portName,
+		targets:      targets,
+		affinity:     affinity,
+		clusterCIDR:  clusterCIDR,
+	}
+}
+
+func (lb *endpointsLB) hash() string {
+	h := sha1.New()
+	for _, t := range lb.targets {
+		fmt.Fprintf(h, "%s/%d\n", t.AddressFrom, t.PortFrom)
+	}
+	if lb.affinity {
+		fmt.Fprintf(h, "affinity\n")
+		if lb.clusterCIDR != nil {
+			fmt.Fprintf(h, "%s\n", lb.clusterCIDR)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (lb *endpointsLB) Convert(lbKey converter.Key, config *converter.Config) ([]converter.BackendResource, error) {
+	// Note: lbKey format should be consistent with the endpoint's
+	// portKey.
+	portKey := fmt.Sprintf("%s/%s", lb.endpointsKey, lb.portName)
+	lbChainID := service.LBChainID(portKey)
+	// Note: "Endpoints DNAT" rather than "Endpoints LB", which was used
+	// when the DNAT Rules accepted the traffic.
+	lbRuleID := converter.IDForKey("Endpoints DNAT", lbKey.Key())
+	targets := lb.targets
+	if lb.affinity && len(targets) > 1 {
+		return lb.affinityRules(lbChainID, lbRuleID), nil
+	}
+	return []converter.BackendResource{
+		// When a DNAT Rule has multiple targets, MidoNet picks
+		// one of them randomly for each new connection.
+		// The choice is remembered by the NAT state of the connection.
+		// It's the equivalent of the kube-proxy probabilistic match.
+		// The Rule returns to the KUBE-SVC Chain for the hairpin SNAT.
+		&midonet.Rule{
+			Parent:     midonet.Parent{ID: &lbChainID},
+			ID:         &lbRuleID,
+			Type:       "dnat",
+			NATTargets: &targets,
+			FlowAction: "return",
 		},
 	}, nil
 }
+
+// affinityBits returns the number of the address bits to distribute
+// clients among the given number of endpoints.
+func affinityBits(n int) uint {
+	// Use several buckets per endpoint for a better distribution.
+	bits := uint(0)
+	for 1<<bits < n*4 && 1<<bits < maxAffinityBuckets {
+		bits++
+	}
+	return bits
+}
+
+// affinityBuckets splits the given IPv4 network into subnets with
+// the given number of additional prefix bits.
+func affinityBuckets(network *net.IPNet, bits uint) []*net.IPNet {
+	ones, _ := network.Mask.Size()
+	if ones+int(bits) > 32 {
+		bits = uint(32 - ones)
+	}
+	length := ones + int(bits)
+	base := binary.BigEndian.Uint32(network.IP.To4())
+	buckets := make([]*net.IPNet, 0, 1<<bits)
+	for i := uint32(0); i < 1<<bits; i++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, base|i<<uint(32-length))
+		buckets = append(buckets, &net.IPNet{IP: ip, Mask: net.CIDRMask(length, 32)})
+	}
+	return buckets
+}
+
+// affinityRules implements ClientIP session affinity.
+//
+// MidoNet Rules don't have a way to remember the choice of
+// the DNAT target beyond a connection.  Instead, we statically map
+// clients to endpoints by the source IP address, using a DNAT Rule
+// with a single target for each bucket of source addresses.
+// As MidoNet Rules can only match an address prefix, a bucket is
+// a subnet.  The Pod network, if configured, is split into buckets so
+// that Pods are distributed among endpoints.  The rest of the addresses
+// are split by the high-order bits.  Clients in the same bucket,
+// e.g. the Pods on the same Node, go to the same endpoint.
+//
+// Note: The mapping is kept as long as the set of endpoints is unchanged,
+// regardless of timeoutSeconds.  See clientIPAffinity.
+func (lb *endpointsLB) affinityRules(lbChainID uuid.UUID, lbRuleID uuid.UUID) []converter.BackendResource {
+	bits := affinityBits(len(lb.targets))
+	_, all, _ := net.ParseCIDR("0.0.0.0/0")
+	// Note: MidoNet inserts a Rule at the top of the Chain unless its
+	// position is specified.  The Pod network buckets come later so
+	// that they are evaluated before the ones for the whole space.
+	groups := []*net.IPNet{all}
+	if lb.clusterCIDR != nil {
+		groups = append(groups, lb.clusterCIDR)
+	}
+	var resources []converter.BackendResource
+	for _, group := range groups {
+		for i, bucket := range affinityBuckets(group, bits) {
+			ruleID := converter.SubID(lbRuleID, fmt.Sprintf("Affinity %s", bucket))
+			targets := []midonet.NATTarget{lb.targets[i%len(lb.targets)]}
+			length, _ := bucket.Mask.Size()
+			resources = append(resources, &midonet.Rule{
+				Parent:       midonet.Parent{ID: &lbChainID},
+				ID:           &ruleID,
+				Type:         "dnat",
+				DLType:       0x800,
+				NWSrcAddress: bucket.IP.String(),
+				NWSrcLength:  length,
+				NATTargets:   &targets,
+				FlowAction:   "return",
+			})
+		}
+	}
+	return resources
+}
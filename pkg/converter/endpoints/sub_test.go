@@ -0,0 +1,202 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package endpoints
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+func TestEndpointsLBHash(t *testing.T) {
+	ep1 := endpoint{ip: "10.1.0.1", port: 80}
+	ep2 := endpoint{ip: "10.1.0.2", port: 80}
+	ep3 := endpoint{ip: "10.1.0.2", port: 8080}
+	lb1 := newEndpointsLB("ns/svc", "http", []endpoint{ep1, ep2}, false, nil)
+	lb2 := newEndpointsLB("ns/svc", "http", []endpoint{ep2, ep1}, false, nil)
+	lb3 := newEndpointsLB("ns/svc", "http", []endpoint{ep1, ep3}, false, nil)
+	if lb1.hash() != lb2.hash() {
+		t.Errorf("hash depends on the order: %v %v", lb1.hash(), lb2.hash())
+	}
+	if lb1.hash() == lb3.hash() {
+		t.Errorf("same hash for different endpoints: %v", lb1.hash())
+	}
+	lb4 := newEndpointsLB("ns/svc", "http", []endpoint{ep1, ep2}, true, nil)
+	if lb1.hash() == lb4.hash() {
+		t.Errorf("same hash regardless of affinity: %v", lb1.hash())
+	}
+}
+
+func TestAffinityBits(t *testing.T) {
+	cases := []struct {
+		n    int
+		want uint
+	}{
+		{1, 2},
+		{2, 3},
+		{3, 4},
+		{8, 5},
+		{1000, 5},
+	}
+	for _, tc := range cases {
+		got := affinityBits(tc.n)
+		if got != tc.want {
+			t.Errorf("affinityBits(%d): got %v\nwant %v", tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestAffinityBuckets(t *testing.T) {
+	_, network, _ := net.ParseCIDR("10.1.0.0/16")
+	buckets := affinityBuckets(network, 2)
+	var got []string
+	for _, b := range buckets {
+		got = append(got, b.String())
+	}
+	expected := []string{"10.1.0.0/18", "10.1.64.0/18", "10.1.128.0/18", "10.1.192.0/18"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v\nwant %v", got, expected)
+	}
+	// Not longer than /32
+	_, network, _ = net.ParseCIDR("10.1.0.0/31")
+	if buckets := affinityBuckets(network, 5); len(buckets) != 2 {
+		t.Errorf("got %v\nwant 2 buckets", buckets)
+	}
+}
+
+func TestAffinityRules(t *testing.T) {
+	ep1 := endpoint{ip: "10.1.0.1", port: 80}
+	ep2 := endpoint{ip: "10.1.0.2", port: 80}
+	_, clusterCIDR, _ := net.ParseCIDR("10.1.0.0/16")
+	lb := newEndpointsLB("ns/svc", "http", []endpoint{ep1, ep2}, true, clusterCIDR)
+	resources, err := lb.Convert(converter.Key{Kind: "Endpoints-LB", Name: "svc/http"}, &converter.Config{})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	// 8 buckets for the whole space and the Pod network each
+	if len(resources) != 16 {
+		t.Fatalf("got %d rules\nwant 16", len(resources))
+	}
+	var sources []string
+	chosen := make(map[string]string)
+	for _, res := range resources {
+		rule := res.(*midonet.Rule)
+		source := fmt.Sprintf("%s/%d", rule.NWSrcAddress, rule.NWSrcLength)
+		sources = append(sources, source)
+		chosen[source] = (*rule.NATTargets)[0].AddressFrom
+		if rule.DLSrc != "" {
+			t.Errorf("got a MAC address match %v", rule.DLSrc)
+		}
+	}
+	// The Pod network comes last so that it's evaluated first.
+	if sources[0] != "0.0.0.0/3" || sources[8] != "10.1.0.0/19" || sources[15] != "10.1.224.0/19" {
+		t.Errorf("unexpected source buckets %v", sources)
+	}
+	if chosen["10.1.0.0/19"] != "10.1.0.1" || chosen["10.1.32.0/19"] != "10.1.0.2" {
+		t.Errorf("unexpected mapping %v", chosen)
+	}
+
+	lb = newEndpointsLB("ns/svc", "http", []endpoint{ep1, ep2}, true, nil)
+	resources, _ = lb.Convert(converter.Key{Kind: "Endpoints-LB", Name: "svc/http"}, &converter.Config{})
+	if len(resources) != 8 {
+		t.Errorf("got %d rules\nwant 8", len(resources))
+	}
+}
+
+func TestClientIPAffinity(t *testing.T) {
+	timeout := func(seconds int32) *v1.SessionAffinityConfig {
+		return &v1.SessionAffinityConfig{
+			ClientIP: &v1.ClientIPConfig{TimeoutSeconds: &seconds},
+		}
+	}
+	cases := []struct {
+		spec v1.ServiceSpec
+		want bool
+	}{
+		{v1.ServiceSpec{SessionAffinity: v1.ServiceAffinityNone}, false},
+		{v1.ServiceSpec{SessionAffinity: v1.ServiceAffinityClientIP}, true},
+		{v1.ServiceSpec{SessionAffinity: v1.ServiceAffinityClientIP, SessionAffinityConfig: timeout(86400)}, true},
+		// The default set by the apiserver
+		{v1.ServiceSpec{SessionAffinity: v1.ServiceAffinityClientIP, SessionAffinityConfig: timeout(10800)}, true},
+		{v1.ServiceSpec{SessionAffinity: v1.ServiceAffinityClientIP, SessionAffinityConfig: timeout(60)}, true},
+	}
+	for _, tc := range cases {
+		if got := clientIPAffinity(&tc.spec); got != tc.want {
+			t.Errorf("%+v: got %v\nwant %v", tc.spec, got, tc.want)
+		}
+	}
+}
+
+// matches returns true if the given Rule matches the addresses.
+func matches(rule *midonet.Rule, src, dst string) bool {
+	match := func(addr string, length int, ip string) bool {
+		if addr == "" {
+			return true
+		}
+		_, network, _ := net.ParseCIDR(fmt.Sprintf("%s/%d", addr, length))
+		return network.Contains(net.ParseIP(ip))
+	}
+	return match(rule.NWSrcAddress, rule.NWSrcLength, src) && match(rule.NWDstAddress, rule.NWDstLength, dst)
+}
+
+func TestHairpinSNAT(t *testing.T) {
+	ep1 := &endpoint{endpointsKey: "ns/svc", portName: "http", svcIP: "10.96.0.10", ip: "10.1.0.1", port: 80}
+	config := &converter.Config{NATPortFrom: 30000, NATPortTo: 60000}
+	resources, err := ep1.Convert(converter.Key{Kind: "Endpoints-Hairpin", Name: "svc/ep1"}, config)
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	rule := resources[0].(*midonet.Rule)
+	// The Rule is evaluated after DNAT.  Only the traffic from
+	// the endpoint to itself is SNAT'ed.
+	if !matches(rule, "10.1.0.1", "10.1.0.1") {
+		t.Errorf("the endpoint reaching itself isn't SNAT'ed: %+v", rule)
+	}
+	if matches(rule, "10.1.0.1", "10.1.0.2") {
+		t.Errorf("the endpoint reaching another endpoint is SNAT'ed: %+v", rule)
+	}
+	if matches(rule, "10.1.0.3", "10.1.0.1") {
+		t.Errorf("a client reaching the endpoint is SNAT'ed: %+v", rule)
+	}
+	if rule.Type != "snat" || (*rule.NATTargets)[0].AddressFrom != "10.96.0.10" {
+		t.Errorf("unexpected Rule %+v", rule)
+	}
+}
+
+func TestDNATReturns(t *testing.T) {
+	// The DNAT Rules return to the KUBE-SVC Chain so that the hairpin
+	// SNAT is evaluated after them.
+	ep1 := endpoint{ip: "10.1.0.1", port: 80}
+	ep2 := endpoint{ip: "10.1.0.2", port: 80}
+	for _, affinity := range []bool{false, true} {
+		lb := newEndpointsLB("ns/svc", "http", []endpoint{ep1, ep2}, affinity, nil)
+		resources, err := lb.Convert(converter.Key{Kind: "Endpoints-LB", Name: "svc/http"}, &converter.Config{})
+		if err != nil {
+			t.Fatalf("Convert: %v", err)
+		}
+		for _, res := range resources {
+			if rule := res.(*midonet.Rule); rule.Type != "dnat" || rule.FlowAction != "return" {
+				t.Errorf("unexpected Rule %+v", rule)
+			}
+		}
+	}
+}
@@ -31,12 +31,26 @@ func ServicesChainID(config *Config) uuid.UUID {
 	return SubID(baseID, "Services Chain")
 }
 
+// NodePortsChainID is the ID of MidoNet Chain which contains the Rules
+// for each NodePorts.
+func NodePortsChainID(config *Config) uuid.UUID {
+	baseID := idForTenant(config.Tenant)
+	return SubID(baseID, "NodePorts Chain")
+}
+
 // MainChainID is the ID of MidoNet Chain which contains the Rules
 // to dispatch to other global Chains including ServicesChainID.
 func MainChainID(config *Config) uuid.UUID {
 	return idForTenant(config.Tenant)
 }
 
+// RouterChainID is the ID of MidoNet Chain which is the inbound filter
+// of the cluster router.
+func RouterChainID(config *Config) uuid.UUID {
+	baseID := idForTenant(config.Tenant)
+	return SubID(baseID, "Router Chain")
+}
+
 // ClusterRouterID is the ID of the cluster router for this deployment.
 func ClusterRouterID(config *Config) uuid.UUID {
 	baseID := idForTenant(config.Tenant)
@@ -55,15 +69,21 @@ func globalResources(config *Config) map[Key]([]BackendResource) {
 	baseID := idForTenant(tenant)
 	mainChainID := baseID
 	clusterRouterID := ClusterRouterID(config)
+	routerChainID := RouterChainID(config)
 	tunnelZoneID := DefaultTunnelZoneID(config)
 	preChainID := SubID(baseID, "Pre Chain")
 	servicesChainID := ServicesChainID(config)
+	nodePortsChainID := NodePortsChainID(config)
 	jumpToPreRuleID := SubID(baseID, "Jump To Pre")
 	jumpToServicesRuleID := SubID(baseID, "Jump To Services")
 	revSNATRuleID := SubID(baseID, "Reverse SNAT")
 	revDNATRuleID := SubID(baseID, "Reverse DNAT")
+	asNumber := 0
+	if config.Uplink != nil {
+		asNumber = config.Uplink.BGPLocalAS
+	}
 	kind := "midonet-global"
-	return map[Key]([]BackendResource){
+	resources := map[Key]([]BackendResource){
 		{Kind: kind, Name: "tunnel-zone"}: []BackendResource{
 			&midonet.TunnelZone{
 				ID:   &tunnelZoneID,
@@ -72,10 +92,24 @@ func globalResources(config *Config) map[Key]([]BackendResource) {
 			},
 		},
 		{Kind: kind, Name: "cluster-router"}: []BackendResource{
+			// Jump rules to the "MAIN" Chain for the traffic from
+			// the uplink are maintained by uplinkResources.
+			&midonet.Chain{
+				ID:       &routerChainID,
+				Name:     "KUBE-ROUTER",
+				TenantID: tenant,
+			},
 			&midonet.Router{
 				ID:       &clusterRouterID,
 				Name:     "ClusterRouter",
 				TenantID: tenant,
+				// Apply the Service rules to the traffic which
+				// reaches the router without going through a Node's
+				// Bridge.  (e.g. the traffic to Service externalIPs)
+				// Note: Not the "MAIN" Chain itself.  The traffic
+				// between Nodes has already been evaluated by it.
+				InboundFilterID: &routerChainID,
+				ASNumber:        asNumber,
 			},
 		},
 		// Chains shared among Bridges for Nodes
@@ -110,8 +144,19 @@ func globalResources(config *Config) map[Key]([]BackendResource) {
 				Type:       "rev_snat",
 				FlowAction: "continue",
 			},
+			// Jump rules to this chain are maintained by the node
+			// controller, for each Node addresses.
+			&midonet.Chain{
+				ID:       &nodePortsChainID,
+				Name:     "KUBE-NODEPORTS",
+				TenantID: tenant,
+			},
 		},
 	}
+	for k, v := range uplinkResources(config) {
+		resources[k] = v
+	}
+	return resources
 }
 
 // EnsureGlobalResources ensures to create Translations for global resources.
@@ -0,0 +1,82 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package converter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+// routerFilter returns the inbound filter of the cluster router and
+// the Rules in it.
+func routerFilter(t *testing.T, config *Config) (*uuid.UUID, []*midonet.Rule) {
+	var router *midonet.Router
+	var rules []*midonet.Rule
+	for _, resources := range globalResources(config) {
+		for _, res := range resources {
+			switch r := res.(type) {
+			case *midonet.Router:
+				router = r
+			case *midonet.Rule:
+				if *r.Parent.ID == RouterChainID(config) {
+					rules = append(rules, r)
+				}
+			}
+		}
+	}
+	if router == nil {
+		t.Fatalf("no cluster router")
+	}
+	return router.InboundFilterID, rules
+}
+
+func TestRouterFilter(t *testing.T) {
+	config := &Config{Tenant: "midonetkube"}
+	filterID, rules := routerFilter(t, config)
+	// The traffic between Nodes doesn't go through the "MAIN" Chain
+	// again.
+	if filterID == nil || *filterID != RouterChainID(config) {
+		t.Errorf("got %v\nwant %v", filterID, RouterChainID(config))
+	}
+	if len(rules) != 0 {
+		t.Errorf("got %v\nwant no Rules without the uplink", rules)
+	}
+
+	hostID := uuid.New()
+	_, subnet, _ := net.ParseCIDR("192.0.2.0/24")
+	_, clusterCIDR, _ := net.ParseCIDR("10.1.0.0/16")
+	config.Uplink = &UplinkConfig{
+		Address:     net.ParseIP("192.0.2.2"),
+		Subnet:      subnet,
+		Gateway:     net.ParseIP("192.0.2.1"),
+		HostID:      &hostID,
+		Interface:   "eth1",
+		ClusterCIDR: clusterCIDR,
+	}
+	_, rules = routerFilter(t, config)
+	if len(rules) != 1 {
+		t.Fatalf("got %v\nwant a Rule for the uplink", rules)
+	}
+	uplinkPortID := SubID(SubID(idForTenant(config.Tenant), "Uplink"), "Port")
+	r := rules[0]
+	if r.Type != "jump" || *r.JumpChainID != MainChainID(config) || len(r.InPorts) != 1 || r.InPorts[0] != uplinkPortID {
+		t.Errorf("unexpected Rule %+v", r)
+	}
+}
@@ -16,6 +16,7 @@
 package converter
 
 import (
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -23,6 +24,20 @@ import (
 	"github.com/midonet/midonet-kubernetes/pkg/controller"
 )
 
+var conversionCount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "midonet_kube_controllers",
+		Subsystem: "converter",
+		Name:      "conversions_total",
+		Help:      "Number of Kubernetes resources converted to Translations",
+	},
+	[]string{"converter", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(conversionCount)
+}
+
 // SubResource is a pseudo resource to represent a part of a k8s resource.
 // For example, we represent a k8s service as a set of "ServicePort"
 // sub resources.
@@ -73,6 +88,19 @@ func (h *converterHandler) convertSubResources(added SubResourceMap, converted m
 }
 
 func (h *converterHandler) Update(strKey string, gvk schema.GroupVersionKind, obj interface{}) error {
+	err := h.update(strKey, gvk, obj)
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	conversionCount.With(prometheus.Labels{
+		"converter": gvk.Kind,
+		"result":    result,
+	}).Inc()
+	return err
+}
+
+func (h *converterHandler) update(strKey string, gvk schema.GroupVersionKind, obj interface{}) error {
 	key, err := newKeyFromClientKey(gvk.Kind, strKey)
 	if err != nil {
 		return err
@@ -0,0 +1,110 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package networkpolicy
+
+import (
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	mncli "github.com/midonet/midonet-kubernetes/pkg/client/clientset/versioned"
+	mninformers "github.com/midonet/midonet-kubernetes/pkg/client/informers/externalversions"
+	"github.com/midonet/midonet-kubernetes/pkg/controller"
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+// NewController creates a networkpolicy controller.
+func NewController(si informers.SharedInformerFactory, msi mninformers.SharedInformerFactory, kc *kubernetes.Clientset, mc *mncli.Clientset, recorder record.EventRecorder, config *converter.Config, _ *midonet.Config) *controller.Controller {
+	informer := si.Networking().V1().NetworkPolicies().Informer()
+	podInformer := si.Core().V1().Pods().Informer()
+	nsInformer := si.Core().V1().Namespaces().Informer()
+	nodeInformer := si.Core().V1().Nodes().Informer()
+	updater := converter.NewTranslationUpdater(mc, recorder)
+	handler := converter.NewHandler(newNetworkPolicyConverter(podInformer, nsInformer, nodeInformer), updater, config)
+	gvk := networkingv1.SchemeGroupVersion.WithKind("NetworkPolicy")
+	c := controller.NewController(gvk, informer, handler)
+	// Kick the NetworkPolicy controller when Pods or Namespaces are
+	// updated, as they can affect any NetworkPolicies via selectors.
+	podInformer.AddEventHandler(newKickAllHandler("pod-netpol", informer, c.GetQueue()))
+	nsInformer.AddEventHandler(newKickAllHandler("ns-netpol", informer, c.GetQueue()))
+	return c
+}
+
+// relevantChange returns true if the update of the object might affect
+// the result of NetworkPolicy conversions.
+func relevantChange(old, new interface{}) bool {
+	oldMeta, err := meta.Accessor(old)
+	if err != nil {
+		return true
+	}
+	newMeta, err := meta.Accessor(new)
+	if err != nil {
+		return true
+	}
+	if !reflect.DeepEqual(oldMeta.GetLabels(), newMeta.GetLabels()) {
+		return true
+	}
+	oldPod, ok := old.(*v1.Pod)
+	if !ok {
+		return false
+	}
+	newPod := new.(*v1.Pod)
+	return oldPod.Spec.NodeName != newPod.Spec.NodeName ||
+		oldPod.Status.PodIP != newPod.Status.PodIP ||
+		oldPod.Status.Phase != newPod.Status.Phase ||
+		!reflect.DeepEqual(oldPod.Spec.Containers, newPod.Spec.Containers)
+}
+
+// newKickAllHandler creates an event handler which queues every
+// NetworkPolicies known to the informer.
+// REVISIT: This is inefficient for clusters with many NetworkPolicies.
+// Probably we can narrow down the affected NetworkPolicies by
+// looking at their selectors.
+func newKickAllHandler(kind string, informer cache.SharedIndexInformer, queue workqueue.Interface) cache.ResourceEventHandler {
+	kick := func(op string) {
+		keys := informer.GetStore().ListKeys()
+		log.WithFields(log.Fields{
+			"op":   op,
+			"kind": kind,
+			"keys": keys,
+		}).Debug("Queueing every NetworkPolicies")
+		for _, key := range keys {
+			queue.Add(key)
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			kick("Add")
+		},
+		UpdateFunc: func(old, new interface{}) {
+			if relevantChange(old, new) {
+				kick("Update")
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			kick("Delete")
+		},
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package networkpolicy
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/converter/node"
+	"github.com/midonet/midonet-kubernetes/pkg/converter/pod"
+)
+
+const (
+	ingress = "ingress"
+	egress  = "egress"
+)
+
+type networkPolicyConverter struct {
+	podInformer  cache.SharedIndexInformer
+	nsInformer   cache.SharedIndexInformer
+	nodeInformer cache.SharedIndexInformer
+}
+
+func newNetworkPolicyConverter(podInformer, nsInformer, nodeInformer cache.SharedIndexInformer) converter.Converter {
+	return &networkPolicyConverter{podInformer, nsInformer, nodeInformer}
+}
+
+// usablePod returns true if the Pod is on our network.
+// It should be consistent with the pod converter.
+func usablePod(pod *v1.Pod) bool {
+	if pod.Spec.NodeName == "" || pod.Spec.HostNetwork {
+		return false
+	}
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return false
+	}
+	ip := net.ParseIP(pod.Status.PodIP)
+	return ip != nil && ip.To4() != nil
+}
+
+func (c *networkPolicyConverter) pods(namespace string, selector labels.Selector) ([]*v1.Pod, error) {
+	objs, err := c.podInformer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]*v1.Pod, 0)
+	for _, obj := range objs {
+		pod := obj.(*v1.Pod)
+		if !usablePod(pod) || !selector.Matches(labels.Set(pod.ObjectMeta.Labels)) {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+func (c *networkPolicyConverter) namespaces(selector labels.Selector) []string {
+	namespaces := make([]string, 0)
+	for _, obj := range c.nsInformer.GetStore().List() {
+		ns := obj.(*v1.Namespace)
+		if selector.Matches(labels.Set(ns.ObjectMeta.Labels)) {
+			namespaces = append(namespaces, ns.ObjectMeta.Name)
+		}
+	}
+	return namespaces
+}
+
+// peerPods returns the Pods matching with the podSelector and/or
+// namespaceSelector of the NetworkPolicyPeer.
+func (c *networkPolicyConverter) peerPods(namespace string, peer *networkingv1.NetworkPolicyPeer) ([]*v1.Pod, error) {
+	podSelector := labels.Everything()
+	if peer.PodSelector != nil {
+		s, err := metav1.LabelSelectorAsSelector(peer.PodSelector)
+		if err != nil {
+			return nil, err
+		}
+		podSelector = s
+	}
+	namespaces := []string{namespace}
+	if peer.NamespaceSelector != nil {
+		s, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		namespaces = c.namespaces(s)
+	}
+	pods := make([]*v1.Pod, 0)
+	for _, ns := range namespaces {
+		l, err := c.pods(ns, podSelector)
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, l...)
+	}
+	return pods, nil
+}
+
+func protocolNumber(protocol v1.Protocol) int {
+	switch protocol {
+	case v1.ProtocolTCP:
+		return 6
+	case v1.ProtocolUDP:
+		return 17
+	}
+	return 0
+}
+
+// resolvePorts returns the list of protocol/port pairs for the given
+// NetworkPolicyPorts.  Named ports are resolved with the given Pod,
+// which can be nil if unknown.
+func resolvePorts(ports []networkingv1.NetworkPolicyPort, pod *v1.Pod) []policyRule {
+	if len(ports) == 0 {
+		// All ports
+		return []policyRule{{}}
+	}
+	result := make([]policyRule, 0, len(ports))
+	for _, p := range ports {
+		protocol := v1.ProtocolTCP
+		if p.Protocol != nil {
+			protocol = *p.Protocol
+		}
+		proto := protocolNumber(protocol)
+		if proto == 0 {
+			log.WithField("protocol", protocol).Warn("Ignoring unknown protocol")
+			continue
+		}
+		if p.Port == nil {
+			result = append(result, policyRule{proto: proto})
+			continue
+		}
+		if p.Port.Type == intstr.Int {
+			result = append(result, policyRule{proto: proto, port: p.Port.IntValue()})
+			continue
+		}
+		if pod == nil {
+			// REVISIT: Named ports for ipBlock peers are not supported.
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			for _, cp := range container.Ports {
+				if cp.Name == p.Port.StrVal && cp.Protocol == protocol {
+					result = append(result, policyRule{proto: proto, port: int(cp.ContainerPort)})
+				}
+			}
+		}
+	}
+	return result
+}
+
+// peerRules returns the flattened rules for the given peers and ports,
+// for the traffic to/from the given Pod.
+func (c *networkPolicyConverter) peerRules(namespace string, pod *v1.Pod, direction string, peers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort) ([]policyRule, error) {
+	// Named ports are for the destination of the traffic.
+	var portPod *v1.Pod
+	if direction == ingress {
+		portPod = pod
+	}
+	rules := make([]policyRule, 0)
+	if len(peers) == 0 {
+		// All sources/destinations
+		return resolvePorts(ports, portPod), nil
+	}
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			_, cidr, err := net.ParseCIDR(peer.IPBlock.CIDR)
+			if err != nil || cidr.IP.To4() == nil {
+				log.WithField("cidr", peer.IPBlock.CIDR).Warn("Ignoring non-IPv4 ipBlock")
+				continue
+			}
+			except := make([]string, 0, len(peer.IPBlock.Except))
+			for _, e := range peer.IPBlock.Except {
+				_, ecidr, err := net.ParseCIDR(e)
+				if err != nil || ecidr.IP.To4() == nil {
+					log.WithField("except", e).Warn("Ignoring non-IPv4 ipBlock except")
+					continue
+				}
+				except = append(except, ecidr.String())
+			}
+			sort.Strings(except)
+			for _, r := range resolvePorts(ports, portPod) {
+				r.cidr = cidr.String()
+				r.except = except
+				rules = append(rules, r)
+			}
+			continue
+		}
+		peerPods, err := c.peerPods(namespace, &peer)
+		if err != nil {
+			return nil, err
+		}
+		for _, peerPod := range peerPods {
+			if direction == egress {
+				portPod = peerPod
+			}
+			for _, r := range resolvePorts(ports, portPod) {
+				r.cidr = fmt.Sprintf("%s/32", peerPod.Status.PodIP)
+				rules = append(rules, r)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// rules returns the flattened rules of the NetworkPolicy for the
+// given Pod and direction.
+func (c *networkPolicyConverter) rules(np *networkingv1.NetworkPolicy, pod *v1.Pod, direction string) ([]policyRule, error) {
+	rules := make([]policyRule, 0)
+	if direction == ingress {
+		for _, r := range np.Spec.Ingress {
+			l, err := c.peerRules(np.ObjectMeta.Namespace, pod, direction, r.From, r.Ports)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, l...)
+		}
+	} else {
+		for _, r := range np.Spec.Egress {
+			l, err := c.peerRules(np.ObjectMeta.Namespace, pod, direction, r.To, r.Ports)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, l...)
+		}
+	}
+	return sortRules(rules), nil
+}
+
+func (c *networkPolicyConverter) nodeIP(nodeName string) (net.IP, error) {
+	obj, exists, err := c.nodeInformer.GetIndexer().GetByKey(nodeName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("node %s is not known yet", nodeName)
+	}
+	si, err := node.GetSubnetInfo(obj.(*v1.Node).Spec.PodCIDR)
+	if err != nil {
+		return nil, err
+	}
+	return si.NodeIP.IP, nil
+}
+
+func (c *networkPolicyConverter) Convert(key converter.Key, obj interface{}, config *converter.Config) ([]converter.BackendResource, converter.SubResourceMap, error) {
+	resources := make([]converter.BackendResource, 0)
+	subs := make(converter.SubResourceMap)
+	np := obj.(*networkingv1.NetworkPolicy)
+	selector, err := metav1.LabelSelectorAsSelector(&np.Spec.PodSelector)
+	if err != nil {
+		return nil, nil, err
+	}
+	pods, err := c.pods(np.ObjectMeta.Namespace, selector)
+	if err != nil {
+		return nil, nil, err
+	}
+	in, out := pod.PolicyTypes(&np.Spec)
+	directions := make([]string, 0, 2)
+	if in {
+		directions = append(directions, ingress)
+	}
+	if out {
+		directions = append(directions, egress)
+	}
+	for _, pod := range pods {
+		podKey := fmt.Sprintf("%s/%s", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name)
+		for _, direction := range directions {
+			iso := &isolation{podKey: podKey, direction: direction}
+			name := fmt.Sprintf("%s/%s/%s", key.Name, pod.ObjectMeta.Name, direction)
+			if direction == ingress {
+				// Always allow the traffic from the Node, e.g. kubelet
+				// health checks.
+				ip, err := c.nodeIP(pod.Spec.NodeName)
+				if err != nil {
+					return nil, nil, err
+				}
+				iso.nodeIP = ip
+				name = fmt.Sprintf("%s/%s", name, ip)
+			}
+			// Note: Rules are not updateable.  Include everything
+			// in the keys so that they are re-created whenever
+			// they got changed.
+			k := converter.Key{
+				Kind: "NetworkPolicy-Isolation",
+				Name: name,
+			}
+			subs[k] = iso
+			rules, err := c.rules(np, pod, direction)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(rules) == 0 {
+				continue
+			}
+			pr := &policyRules{podKey: podKey, direction: direction, rules: rules}
+			k = converter.Key{
+				Kind: "NetworkPolicy-Rules",
+				Name: fmt.Sprintf("%s/%s/%s/%s", key.Name, pod.ObjectMeta.Name, direction, pr.hash()),
+			}
+			subs[k] = pr
+		}
+	}
+	return resources, subs, nil
+}
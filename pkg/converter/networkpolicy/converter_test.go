@@ -0,0 +1,129 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package networkpolicy
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/converter/pod"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+func TestResolvePorts(t *testing.T) {
+	udp := v1.ProtocolUDP
+	http := intstr.FromString("http")
+	dns := intstr.FromInt(53)
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Ports: []v1.ContainerPort{
+						{Name: "http", ContainerPort: 8080, Protocol: v1.ProtocolTCP},
+					},
+				},
+			},
+		},
+	}
+	ports := []networkingv1.NetworkPolicyPort{
+		{Port: &http},
+		{Protocol: &udp, Port: &dns},
+		{Protocol: &udp},
+	}
+	got := resolvePorts(ports, pod)
+	want := []policyRule{
+		{proto: 6, port: 8080},
+		{proto: 17, port: 53},
+		{proto: 17},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	got = resolvePorts(ports, nil)
+	want = want[1:]
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	got = resolvePorts(nil, nil)
+	want = []policyRule{{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestSortRules(t *testing.T) {
+	r1 := policyRule{cidr: "10.1.0.1/32", proto: 6, port: 80}
+	r2 := policyRule{cidr: "10.1.0.2/32", proto: 6, port: 80}
+	got := sortRules([]policyRule{r2, r1, r2})
+	want := []policyRule{r1, r2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestConvertEgress(t *testing.T) {
+	config := &converter.Config{Tenant: "midonetkube"}
+	key := converter.Key{Kind: "NetworkPolicy-Rules", Name: "ns/policy/ns/pod/egress"}
+	// Allow DNS to the kube-dns Pod.  As the egress Chain is evaluated
+	// after the Service DNAT, the Pod IP and the target port match
+	// the traffic to the kube-dns Service.
+	rules := &policyRules{
+		podKey:    "ns/pod",
+		direction: egress,
+		rules:     []policyRule{{cidr: "10.1.2.3/32", proto: 17, port: 53}},
+	}
+	resources, err := rules.Convert(key, config)
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if len(resources) != 1 {
+		t.Fatalf("got %v\nwant a Rule", resources)
+	}
+	rule := resources[0].(*midonet.Rule)
+	allowChainID := pod.EgressAllowChainID("ns/pod")
+	expected := &midonet.Rule{
+		Parent:       midonet.Parent{ID: &allowChainID},
+		ID:           rule.ID,
+		Type:         "accept",
+		DLType:       0x800,
+		NWProto:      17,
+		NWDstAddress: "10.1.2.3",
+		NWDstLength:  32,
+		TPDst:        &midonet.PortRange{Start: 53, End: 53},
+	}
+	if !reflect.DeepEqual(rule, expected) {
+		t.Errorf("got %+v\nwant %+v", rule, expected)
+	}
+
+	iso := &isolation{podKey: "ns/pod", direction: egress}
+	resources, err = iso.Convert(converter.Key{Kind: "NetworkPolicy-Isolation", Name: "ns/pod/egress"}, config)
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	isolateChainID := pod.EgressIsolateChainID("ns/pod")
+	if len(resources) != 1 {
+		t.Fatalf("got %v\nwant a Rule", resources)
+	}
+	drop := resources[0].(*midonet.Rule)
+	if drop.Type != "drop" || *drop.Parent.ID != isolateChainID || drop.DLType != 0x800 {
+		t.Errorf("unexpected Rule %+v", drop)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package networkpolicy
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/converter/pod"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+// policyRule is a flattened NetworkPolicy rule, that is, a pair of
+// a peer and a port.
+type policyRule struct {
+	// The peer address.  An empty string means any.
+	cidr   string
+	except []string
+
+	// Zero means any.
+	proto int
+	port  int
+}
+
+func (r *policyRule) String() string {
+	return fmt.Sprintf("%s/%s/%d/%d", r.cidr, strings.Join(r.except, ","), r.proto, r.port)
+}
+
+// sortRules sorts and de-duplicates the rules so that the same set of
+// rules always produces the same hash.
+func sortRules(rules []policyRule) []policyRule {
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].String() < rules[j].String()
+	})
+	result := make([]policyRule, 0, len(rules))
+	for i := range rules {
+		if i > 0 && rules[i].String() == rules[i-1].String() {
+			continue
+		}
+		result = append(result, rules[i])
+	}
+	return result
+}
+
+func chainIDs(podKey, direction string) (uuid.UUID, uuid.UUID) {
+	if direction == ingress {
+		return pod.IngressAllowChainID(podKey), pod.IngressIsolateChainID(podKey)
+	}
+	return pod.EgressAllowChainID(podKey), pod.EgressIsolateChainID(podKey)
+}
+
+// isolation is a pseudo resource to represent the fact that a Pod is
+// selected by a NetworkPolicy.
+type isolation struct {
+	podKey    string
+	direction string
+	nodeIP    net.IP
+}
+
+func (i *isolation) Convert(key converter.Key, config *converter.Config) ([]converter.BackendResource, error) {
+	_, isolateChainID := chainIDs(i.podKey, i.direction)
+	baseID := converter.IDForKey("NetworkPolicyIsolation", key.Key())
+	dropRuleID := converter.SubID(baseID, "Drop")
+	// Note: Every NetworkPolicies selecting the Pod add their own Rules.
+	// Duplicate Rules are harmless.
+	// Note: Only drop IPv4 traffic.  In particular, we don't want to
+	// drop ARP.
+	resources := []converter.BackendResource{
+		&midonet.Rule{
+			Parent: midonet.Parent{ID: &isolateChainID},
+			ID:     &dropRuleID,
+			Type:   "drop",
+			DLType: 0x800,
+		},
+	}
+	if i.nodeIP != nil {
+		// Note: MidoNet inserts a Rule at the top of the Chain.
+		// This Rule is evaluated before the above drop Rule.
+		acceptRuleID := converter.SubID(baseID, "Accept Node")
+		resources = append(resources, &midonet.Rule{
+			Parent:       midonet.Parent{ID: &isolateChainID},
+			ID:           &acceptRuleID,
+			Type:         "accept",
+			DLType:       0x800,
+			NWSrcAddress: i.nodeIP.String(),
+			NWSrcLength:  32,
+		})
+	}
+	return resources, nil
+}
+
+// policyRules is a pseudo resource to represent the traffic a
+// NetworkPolicy allows for a Pod.
+type policyRules struct {
+	podKey    string
+	direction string
+	rules     []policyRule
+}
+
+func (p *policyRules) hash() string {
+	h := sha1.New()
+	for _, r := range p.rules {
+		fmt.Fprintf(h, "%s\n", r.String())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// rule returns a Rule matching with the peer address in the given CIDR
+// and the port.
+func (p *policyRules) rule(typ string, cidr string, r *policyRule) *midonet.Rule {
+	rule := &midonet.Rule{
+		Type:    typ,
+		DLType:  0x800,
+		NWProto: r.proto,
+	}
+	if r.port != 0 {
+		rule.TPDst = &midonet.PortRange{Start: r.port, End: r.port}
+	}
+	if cidr != "" {
+		_, ipnet, _ := net.ParseCIDR(cidr)
+		length, _ := ipnet.Mask.Size()
+		if p.direction == ingress {
+			rule.NWSrcAddress = ipnet.IP.String()
+			rule.NWSrcLength = length
+		} else {
+			rule.NWDstAddress = ipnet.IP.String()
+			rule.NWDstLength = length
+		}
+	}
+	return rule
+}
+
+func (p *policyRules) Convert(key converter.Key, config *converter.Config) ([]converter.BackendResource, error) {
+	allowChainID, _ := chainIDs(p.podKey, p.direction)
+	baseID := converter.IDForKey("NetworkPolicyRules", key.Key())
+	resources := make([]converter.BackendResource, 0, len(p.rules))
+	for i := range p.rules {
+		r := &p.rules[i]
+		ruleID := converter.SubID(baseID, fmt.Sprintf("Rule %d", i))
+		accept := p.rule("accept", r.cidr, r)
+		accept.ID = &ruleID
+		if len(r.except) == 0 {
+			accept.Parent = midonet.Parent{ID: &allowChainID}
+			resources = append(resources, accept)
+			continue
+		}
+		// An ipBlock with exceptions is implemented with a separate
+		// Chain, which returns to the allow Chain for the excepted
+		// addresses.
+		chainID := converter.SubID(baseID, fmt.Sprintf("Chain %d", i))
+		jumpRuleID := converter.SubID(baseID, fmt.Sprintf("Jump %d", i))
+		accept.Parent = midonet.Parent{ID: &chainID}
+		resources = append(resources,
+			&midonet.Chain{
+				ID:       &chainID,
+				Name:     fmt.Sprintf("KUBE-NP-%s-%d", key.Name, i),
+				TenantID: config.Tenant,
+			},
+			accept,
+		)
+		// Note: MidoNet inserts a Rule at the top of the Chain.
+		// These Rules are evaluated before the above accept Rule.
+		for j, e := range r.except {
+			returnRuleID := converter.SubID(baseID, fmt.Sprintf("Rule %d Except %d", i, j))
+			ret := p.rule("return", e, &policyRule{})
+			ret.Parent = midonet.Parent{ID: &chainID}
+			ret.ID = &returnRuleID
+			resources = append(resources, ret)
+		}
+		resources = append(resources, midonet.JumpRule(&jumpRuleID, &allowChainID, &chainID))
+	}
+	return resources, nil
+}
@@ -59,6 +59,14 @@ func nodeAddresses(nodeKey converter.Key, routerPortID uuid.UUID, nodeIP net.IP,
 				"address": a.Address,
 			}).Fatal("Unparsable Node Address")
 		}
+		if ip.To4() == nil {
+			// REVISIT: IPv6 is not supported yet.
+			log.WithFields(log.Fields{
+				"node":    nodeKey,
+				"address": a.Address,
+			}).Debug("Ignoring non-IPv4 Node Address")
+			continue
+		}
 		key := converter.Key{
 			Kind: "Node-Address",
 			Name: fmt.Sprintf("%s/%s/%s", nodeKey.Name, typ, ip),
@@ -88,6 +96,7 @@ func (c *nodeConverter) Convert(key converter.Key, obj interface{}, config *conv
 	nodePortID := portIDForKey(key.Key())
 	nodePortChainID := converter.SubID(baseID, "Node Port Chain")
 	nodeSNATRuleID := converter.SubID(baseID, "Node Port SNAT Rule")
+	nodePortsRuleID := converter.SubID(baseID, "Jump Gateway to NodePorts")
 	routerPortID := converter.SubID(baseID, "Router Port")
 	subnetRouteID := converter.SubID(baseID, "Route")
 	spec := obj.(*v1.Node).Spec
@@ -98,6 +107,12 @@ func (c *nodeConverter) Convert(key converter.Key, obj interface{}, config *conv
 	if err != nil {
 		log.WithField("node", obj).Fatal("Failed to parse PodCIDR")
 	}
+	if si.Subnet.IP.To4() == nil {
+		// REVISIT: IPv6 is not supported yet.  Note that MidoNet
+		// NAT Rules, which we use for Services, are IPv4 only.
+		log.WithField("podCIDR", spec.PodCIDR).Warn("Ignoring Node with non-IPv4 PodCIDR")
+		return nil, nil, nil
+	}
 	routerPortSubnet := []*types.IPNet{
 		{si.GatewayIP.IP, si.GatewayIP.Mask},
 	}
@@ -112,6 +127,9 @@ func (c *nodeConverter) Convert(key converter.Key, obj interface{}, config *conv
 		return nil, nil, nil
 	}
 	mainChainID := converter.MainChainID(config)
+	servicesChainID := converter.ServicesChainID(config)
+	nodePortsChainID := converter.NodePortsChainID(config)
+	egressDispatchChainID := pod.EgressDispatchChainID(key.Key())
 	subs := nodeAddresses(key, routerPortID, nodeIP, status.Addresses)
 	tunnelZoneID, err := getTunnelZoneID(meta.Annotations[converter.TunnelZoneIDAnnotation], config)
 	if err == nil {
@@ -156,11 +174,20 @@ func (c *nodeConverter) Convert(key converter.Key, obj interface{}, config *conv
 		}
 	}
 	return []converter.BackendResource{
+		// Jump rules to the egress filter Chains of Pods are
+		// maintained by the pod controller.
+		&midonet.Chain{
+			ID:       &egressDispatchChainID,
+			Name:     fmt.Sprintf("KUBE-EGRESS-DISPATCH-%s", key.Key()),
+			TenantID: config.Tenant,
+		},
 		&midonet.Bridge{
 			ID:              &bridgeID,
 			Name:            bridgeName,
 			TenantID:        config.Tenant,
 			InboundFilterID: &mainChainID,
+			// The egress filters of Pods
+			OutboundFilterID: &egressDispatchChainID,
 		},
 		&midonet.Port{
 			Parent: midonet.Parent{ID: &bridgeID},
@@ -232,12 +259,24 @@ func (c *nodeConverter) Convert(key converter.Key, obj interface{}, config *conv
 				{
 					AddressFrom: gatewayIP,
 					AddressTo:   gatewayIP,
-					// REVISIT: arbitrary port range
-					PortFrom: 30000,
-					PortTo:   60000,
+					PortFrom:    config.NATPortFrom,
+					PortTo:      config.NATPortTo,
 				},
 			},
 			FlowAction: "continue",
 		},
+		// Dispatch the traffic to the gateway IP to NodePort Services.
+		// midonet-kube-node DNATs the NodePort traffic arriving on
+		// the host to the gateway IP, with the source masqueraded
+		// to the Node IP.
+		&midonet.Rule{
+			Parent:       midonet.Parent{ID: &servicesChainID},
+			ID:           &nodePortsRuleID,
+			DLType:       0x800,
+			NWDstAddress: gatewayIP,
+			NWDstLength:  32,
+			Type:         "jump",
+			JumpChainID:  &nodePortsChainID,
+		},
 	}, subs, nil
 }
@@ -33,6 +33,9 @@ type nodeAddress struct {
 func (i *nodeAddress) Convert(key converter.Key, config *converter.Config) ([]converter.BackendResource, error) {
 	routerID := converter.ClusterRouterID(config)
 	routeID := converter.IDForKey("Node Address", key.Key())
+	servicesChainID := converter.ServicesChainID(config)
+	nodePortsChainID := converter.NodePortsChainID(config)
+	jumpRuleID := converter.SubID(routeID, "Jump to NodePorts")
 	return []converter.BackendResource{
 		// Forward the traffic to Node.Status.Addresses to the Node IP,
 		// assuming that the node network can forward it.
@@ -47,5 +50,19 @@ func (i *nodeAddress) Convert(key converter.Key, config *converter.Config) ([]co
 			NextHopGateway:   i.nodeIP,
 			Type:             "Normal",
 		},
+		// Dispatch the traffic to the address to NodePort Services.
+		// Note: Only the traffic which is already in the MidoNet
+		// network, e.g. from Pods, comes here.  The traffic arriving
+		// on the Node's own interface is forwarded by midonet-kube-node
+		// to the gateway IP of the Node instead.
+		&midonet.Rule{
+			Parent:       midonet.Parent{ID: &servicesChainID},
+			ID:           &jumpRuleID,
+			DLType:       0x800,
+			NWDstAddress: i.ip.String(),
+			NWDstLength:  32,
+			Type:         "jump",
+			JumpChainID:  &nodePortsChainID,
+		},
 	}, nil
 }
@@ -0,0 +1,74 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pod
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+)
+
+// The following IDs are shared with the networkpolicy converter,
+// which adds Rules to these Chains.
+// podKey is "Namespace/Name".
+
+// The filter Chains for the Pod.
+// The ingress Chain is the outbound filter of the Bridge Port for the Pod.
+// The egress Chain is jumped to from the egress dispatch Chain of
+// the Node, so that it sees the traffic to Services after DNAT.
+// The egress Chain and the allow/isolate Chains for a direction exist
+// only while a NetworkPolicy selects the Pod for the direction.
+// (See policyChains)
+
+func ingressChainID(podKey string) uuid.UUID {
+	return converter.SubID(idForKey(podKey), "Ingress Chain")
+}
+
+func egressChainID(podKey string) uuid.UUID {
+	return converter.SubID(idForKey(podKey), "Egress Chain")
+}
+
+// IngressAllowChainID returns the ID of the Chain which contains Rules
+// to accept the traffic to the Pod.
+func IngressAllowChainID(podKey string) uuid.UUID {
+	return converter.SubID(ingressChainID(podKey), "Allow Chain")
+}
+
+// IngressIsolateChainID returns the ID of the Chain which contains Rules
+// to drop the traffic to the Pod not accepted by the IngressAllowChain.
+func IngressIsolateChainID(podKey string) uuid.UUID {
+	return converter.SubID(ingressChainID(podKey), "Isolate Chain")
+}
+
+// EgressAllowChainID returns the ID of the Chain which contains Rules
+// to accept the traffic from the Pod.
+func EgressAllowChainID(podKey string) uuid.UUID {
+	return converter.SubID(egressChainID(podKey), "Allow Chain")
+}
+
+// EgressIsolateChainID returns the ID of the Chain which contains Rules
+// to drop the traffic from the Pod not accepted by the EgressAllowChain.
+func EgressIsolateChainID(podKey string) uuid.UUID {
+	return converter.SubID(egressChainID(podKey), "Isolate Chain")
+}
+
+// EgressDispatchChainID returns the ID of the Chain which dispatches
+// the traffic from the Pods on the Node to their egress Chains.
+// It's the outbound filter of the Bridge for the Node, which is
+// evaluated after the Service DNAT in the inbound filter.
+func EgressDispatchChainID(nodeName string) uuid.UUID {
+	return converter.SubID(converter.IDForKey("Node", nodeName), "Egress Dispatch Chain")
+}
@@ -16,10 +16,13 @@
 package pod
 
 import (
+	log "github.com/sirupsen/logrus"
 	"k8s.io/api/core/v1"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 
 	mncli "github.com/midonet/midonet-kubernetes/pkg/client/clientset/versioned"
 	mninformers "github.com/midonet/midonet-kubernetes/pkg/client/informers/externalversions"
@@ -32,8 +35,56 @@ import (
 func NewController(si informers.SharedInformerFactory, msi mninformers.SharedInformerFactory, kc *kubernetes.Clientset, mc *mncli.Clientset, recorder record.EventRecorder, config *converter.Config, _ *midonet.Config) *controller.Controller {
 	informer := si.Core().V1().Pods().Informer()
 	nodeInformer := si.Core().V1().Nodes().Informer()
+	npInformer := si.Networking().V1().NetworkPolicies().Informer()
 	updater := converter.NewTranslationUpdater(mc, recorder)
-	handler := converter.NewHandler(newPodConverter(nodeInformer), updater, config)
+	handler := converter.NewHandler(newPodConverter(nodeInformer, npInformer), updater, config)
 	gvk := v1.SchemeGroupVersion.WithKind("Pod")
-	return controller.NewController(gvk, informer, handler)
+	c := controller.NewController(gvk, informer, handler)
+	// Kick the Pods in the namespace when a NetworkPolicy is updated,
+	// as it can change the set of the filter Chains of the Pods.
+	npInformer.AddEventHandler(newNamespaceHandler("netpol-pod", informer, c.GetQueue()))
+	return c
+}
+
+// newNamespaceHandler creates an event handler which queues every Pods
+// in the namespace of the object.
+func newNamespaceHandler(kind string, informer cache.SharedIndexInformer, queue workqueue.Interface) cache.ResourceEventHandler {
+	kick := func(op string, obj interface{}) {
+		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			log.WithError(err).Fatal("DeletionHandlingMetaNamespaceKeyFunc")
+		}
+		namespace, _, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			log.WithError(err).Fatal("SplitMetaNamespaceKey")
+		}
+		objs, err := informer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+		if err != nil {
+			log.WithError(err).Error("ByIndex")
+			return
+		}
+		log.WithFields(log.Fields{
+			"op":        op,
+			"kind":      kind,
+			"namespace": namespace,
+		}).Debug("Queueing every Pods in the namespace")
+		for _, obj := range objs {
+			key, err := cache.MetaNamespaceKeyFunc(obj)
+			if err != nil {
+				log.WithError(err).Fatal("MetaNamespaceKeyFunc")
+			}
+			queue.Add(key)
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			kick("Add", obj)
+		},
+		UpdateFunc: func(old, new interface{}) {
+			kick("Update", new)
+		},
+		DeleteFunc: func(obj interface{}) {
+			kick("Delete", obj)
+		},
+	}
 }
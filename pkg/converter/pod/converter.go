@@ -34,10 +34,11 @@ func idForKey(key string) uuid.UUID {
 
 type podConverter struct {
 	nodeInformer cache.SharedIndexInformer
+	npInformer   cache.SharedIndexInformer
 }
 
-func newPodConverter(nodeInformer cache.SharedIndexInformer) converter.Converter {
-	return &podConverter{nodeInformer}
+func newPodConverter(nodeInformer, npInformer cache.SharedIndexInformer) converter.Converter {
+	return &podConverter{nodeInformer, npInformer}
 }
 
 func (c *podConverter) Convert(key converter.Key, obj interface{}, config *converter.Config) ([]converter.BackendResource, converter.SubResourceMap, error) {
@@ -75,11 +76,21 @@ func (c *podConverter) Convert(key converter.Key, obj interface{}, config *conve
 		// Retry later.  Note: we don't listen Node events.
 		return nil, nil, err
 	}
+	podKey := key.Key()
+	ingressFilterID := ingressChainID(podKey)
 	res := []converter.BackendResource{
+		// Note: The Chain has no Rules unless a NetworkPolicy selects
+		// the Pod for ingress.
+		&midonet.Chain{
+			ID:       &ingressFilterID,
+			Name:     fmt.Sprintf("KUBE-POD-INGRESS-%s", podKey),
+			TenantID: config.Tenant,
+		},
 		&midonet.Port{
-			Parent: midonet.Parent{ID: &bridgeID},
-			ID:     &bridgePortID,
-			Type:   "Bridge",
+			Parent:           midonet.Parent{ID: &bridgeID},
+			ID:               &bridgePortID,
+			Type:             "Bridge",
+			OutboundFilterID: &ingressFilterID,
 		},
 		&midonet.HostInterfacePort{
 			Parent:        midonet.Parent{ID: &hostID},
@@ -88,6 +99,29 @@ func (c *podConverter) Convert(key converter.Key, obj interface{}, config *conve
 			InterfaceName: IFNameForKey(key.Key()),
 		},
 	}
+	in, out, err := selectedDirections(c.npInformer, obj.(*v1.Pod))
+	if err != nil {
+		return nil, nil, err
+	}
+	if in {
+		skey := converter.Key{
+			Kind: "Pod-Policy-Chains",
+			Name: fmt.Sprintf("%s/%s", key.Name, ingress),
+		}
+		subs[skey] = &policyChains{podKey: podKey, direction: ingress}
+	}
+	if out {
+		skey := converter.Key{
+			Kind: "Pod-Policy-Chains",
+			Name: fmt.Sprintf("%s/%s", key.Name, egress),
+		}
+		subs[skey] = &policyChains{
+			podKey:    podKey,
+			direction: egress,
+			nodeName:  nodeName,
+			portID:    bridgePortID,
+		}
+	}
 	macStr, exists := meta.Annotations[converter.MACAnnotation]
 	if exists {
 		mac, err := net.ParseMAC(macStr)
@@ -105,7 +139,8 @@ func (c *podConverter) Convert(key converter.Key, obj interface{}, config *conve
 			MAC:      mac,
 		}
 		ip := net.ParseIP(status.PodIP)
-		if ip != nil {
+		// Note: IPv4MACPair is, as the name suggests, IPv4 only.
+		if ip != nil && ip.To4() != nil {
 			skey := converter.Key{
 				Kind:        "Pod-ARP",
 				Name:        fmt.Sprintf("%s/ip/%s/%s", key.Name, ip, DNSifyMAC(mac)),
@@ -0,0 +1,142 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pod
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+func TestConvertPolicyChains(t *testing.T) {
+	nodeInformer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &v1.Node{}, 0, cache.Indexers{})
+	nodeInformer.GetIndexer().Add(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node1",
+			Annotations: map[string]string{
+				converter.HostIDAnnotation: uuid.New().String(),
+			},
+		},
+	})
+	npInformer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &networkingv1.NetworkPolicy{}, 0, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "pod",
+			Labels:    map[string]string{"app": "web"},
+		},
+		Spec: v1.PodSpec{NodeName: "node1"},
+	}
+	config := &converter.Config{Tenant: "midonetkube"}
+	key := converter.Key{Kind: "Pod", Name: "ns/pod"}
+	c := newPodConverter(nodeInformer, npInformer)
+
+	// Without NetworkPolicies, only the ingress Chain for the Port.
+	resources, subs, err := c.Convert(key, pod, config)
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	var port *midonet.Port
+	chains := 0
+	for _, res := range resources {
+		switch r := res.(type) {
+		case *midonet.Port:
+			port = r
+		case *midonet.Chain:
+			chains++
+		}
+	}
+	if chains != 1 || len(subs) != 0 {
+		t.Errorf("got %d Chains and %v\nwant only the ingress Chain", chains, subs)
+	}
+	// The egress Chain is evaluated by the Bridge, after the Service DNAT.
+	if port == nil || port.InboundFilterID != nil || *port.OutboundFilterID != ingressChainID("ns/pod") {
+		t.Errorf("unexpected Port %+v", port)
+	}
+
+	npInformer.GetIndexer().Add(&networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "policy"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+		},
+	})
+	_, subs, err = c.Convert(key, pod, config)
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if len(subs) != 1 {
+		t.Fatalf("got %v\nwant the egress Chains", subs)
+	}
+	var jump *midonet.Rule
+	chainNames := make(map[uuid.UUID]string)
+	for k, sub := range subs {
+		resources, err := sub.Convert(k, config)
+		if err != nil {
+			t.Fatalf("Convert: %v", err)
+		}
+		for _, res := range resources {
+			switch r := res.(type) {
+			case *midonet.Chain:
+				chainNames[*r.ID] = r.Name
+			case *midonet.Rule:
+				if *r.Parent.ID == EgressDispatchChainID("node1") {
+					jump = r
+				}
+			}
+		}
+	}
+	egressID := egressChainID("ns/pod")
+	if chainNames[egressID] != "KUBE-POD-EGRESS-ns/pod" || len(chainNames) != 3 {
+		t.Errorf("got chains %v\nwant the egress, allow and isolate Chains", chainNames)
+	}
+	if jump == nil {
+		t.Fatalf("no jump Rule to the egress Chain")
+	}
+	if jump.Type != "jump" || *jump.JumpChainID != egressID || !reflect.DeepEqual(jump.InPorts, []uuid.UUID{idForKey("ns/pod")}) {
+		t.Errorf("unexpected Rule %+v", jump)
+	}
+}
+
+func TestPolicyTypes(t *testing.T) {
+	cases := []struct {
+		spec networkingv1.NetworkPolicySpec
+		in   bool
+		out  bool
+	}{
+		{networkingv1.NetworkPolicySpec{}, true, false},
+		{networkingv1.NetworkPolicySpec{
+			Egress: []networkingv1.NetworkPolicyEgressRule{{}},
+		}, true, true},
+		{networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+		}, false, true},
+	}
+	for _, tc := range cases {
+		in, out := PolicyTypes(&tc.spec)
+		if in != tc.in || out != tc.out {
+			t.Errorf("%v: got %v %v\nwant %v %v", tc.spec, in, out, tc.in, tc.out)
+		}
+	}
+}
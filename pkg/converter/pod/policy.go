@@ -0,0 +1,155 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pod
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+const (
+	ingress = "ingress"
+	egress  = "egress"
+)
+
+// PolicyTypes returns whether the NetworkPolicy applies to ingress
+// and egress traffic respectively.
+func PolicyTypes(spec *networkingv1.NetworkPolicySpec) (bool, bool) {
+	if len(spec.PolicyTypes) == 0 {
+		// The default is Ingress, plus Egress if there are egress rules.
+		return true, len(spec.Egress) > 0
+	}
+	var in, out bool
+	for _, t := range spec.PolicyTypes {
+		switch t {
+		case networkingv1.PolicyTypeIngress:
+			in = true
+		case networkingv1.PolicyTypeEgress:
+			out = true
+		}
+	}
+	return in, out
+}
+
+// selectedDirections returns whether any NetworkPolicies select
+// the Pod for ingress and egress traffic respectively.
+func selectedDirections(npInformer cache.SharedIndexInformer, pod *v1.Pod) (bool, bool, error) {
+	objs, err := npInformer.GetIndexer().ByIndex(cache.NamespaceIndex, pod.ObjectMeta.Namespace)
+	if err != nil {
+		return false, false, err
+	}
+	var in, out bool
+	for _, obj := range objs {
+		np := obj.(*networkingv1.NetworkPolicy)
+		selector, err := metav1.LabelSelectorAsSelector(&np.Spec.PodSelector)
+		if err != nil {
+			return false, false, err
+		}
+		if !selector.Matches(labels.Set(pod.ObjectMeta.Labels)) {
+			continue
+		}
+		npIn, npOut := PolicyTypes(&np.Spec)
+		in = in || npIn
+		out = out || npOut
+	}
+	return in, out, nil
+}
+
+// policyChains is a pseudo resource to represent the filter Chains
+// of a Pod for a direction, which is selected by NetworkPolicies.
+type policyChains struct {
+	podKey    string
+	direction string
+
+	// For egress
+	nodeName string
+	portID   uuid.UUID
+}
+
+func (p *policyChains) Convert(key converter.Key, config *converter.Config) ([]converter.BackendResource, error) {
+	if p.direction == ingress {
+		// Note: The ingress Chain itself belongs to the Pod as
+		// the outbound filter of its Bridge Port.
+		return filterRules("INGRESS", p.podKey, ingressChainID(p.podKey), IngressAllowChainID(p.podKey), IngressIsolateChainID(p.podKey), config), nil
+	}
+	chainID := egressChainID(p.podKey)
+	dispatchChainID := EgressDispatchChainID(p.nodeName)
+	jumpRuleID := converter.SubID(chainID, "Jump From Dispatch")
+	resources := []converter.BackendResource{
+		&midonet.Chain{
+			ID:       &chainID,
+			Name:     fmt.Sprintf("KUBE-POD-EGRESS-%s", p.podKey),
+			TenantID: config.Tenant,
+		},
+	}
+	resources = append(resources, filterRules("EGRESS", p.podKey, chainID, EgressAllowChainID(p.podKey), EgressIsolateChainID(p.podKey), config)...)
+	// Note: Rather than the inbound filter of the Port, the egress
+	// Chain is evaluated by the outbound filter of the Bridge.
+	// Otherwise, it would see the traffic to Services before DNAT
+	// and an egress-isolated Pod couldn't reach any Services.
+	return append(resources, &midonet.Rule{
+		Parent:      midonet.Parent{ID: &dispatchChainID},
+		ID:          &jumpRuleID,
+		Type:        "jump",
+		JumpChainID: &chainID,
+		InPorts:     []uuid.UUID{p.portID},
+	}), nil
+}
+
+// filterRules returns the allow and isolate Chains for the given
+// direction, which are populated by the networkpolicy converter,
+// and the Rules in the filter Chain to jump to them.
+func filterRules(direction string, podKey string, chainID, allowChainID, isolateChainID uuid.UUID, config *converter.Config) []converter.BackendResource {
+	returnFlowRuleID := converter.SubID(chainID, "Accept Return Flow")
+	jumpToAllowRuleID := converter.SubID(chainID, "Jump To Allow")
+	jumpToIsolateRuleID := converter.SubID(chainID, "Jump To Isolate")
+	return []converter.BackendResource{
+		&midonet.Chain{
+			ID:       &allowChainID,
+			Name:     fmt.Sprintf("KUBE-POD-%s-ALLOW-%s", direction, podKey),
+			TenantID: config.Tenant,
+		},
+		&midonet.Chain{
+			ID:       &isolateChainID,
+			Name:     fmt.Sprintf("KUBE-POD-%s-ISOLATE-%s", direction, podKey),
+			TenantID: config.Tenant,
+		},
+		// Note: MidoNet inserts a Rule at the top of the Chain
+		// unless its position is specified.  The following order
+		// results in:
+		//   1. Accept the return traffic of the accepted connections
+		//   2. Jump to the allow chain, which accepts the allowed traffic
+		//   3. Jump to the isolate chain, which drops everything else
+		midonet.JumpRule(&jumpToIsolateRuleID, &chainID, &isolateChainID),
+		midonet.JumpRule(&jumpToAllowRuleID, &chainID, &allowChainID),
+		&midonet.Rule{
+			Parent:          midonet.Parent{ID: &chainID},
+			ID:              &returnFlowRuleID,
+			Type:            "accept",
+			DLType:          0x800,
+			MatchReturnFlow: true,
+		},
+	}
+}
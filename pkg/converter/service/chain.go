@@ -0,0 +1,43 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package service
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+)
+
+// The following IDs are shared with the endpoints converter, which
+// adds Rules to these Chains.
+// portKey is "Namespace/Name/ServicePort.Name".
+
+// PortChainID returns the ID of the KUBE-SVC Chain for the ServicePort.
+func PortChainID(portKey string) uuid.UUID {
+	return converter.IDForKey("ServicePort", portKey)
+}
+
+// HairpinChainID returns the ID of the Chain which contains SNAT Rules
+// for the traffic from the endpoints of the ServicePort.
+func HairpinChainID(portKey string) uuid.UUID {
+	return converter.SubID(PortChainID(portKey), "Hairpin Chain")
+}
+
+// LBChainID returns the ID of the Chain which contains the DNAT Rule
+// to distribute the traffic among the endpoints of the ServicePort.
+func LBChainID(portKey string) uuid.UUID {
+	return converter.SubID(PortChainID(portKey), "LB Chain")
+}
@@ -17,6 +17,7 @@ package service
 
 import (
 	"fmt"
+	"net"
 
 	log "github.com/sirupsen/logrus"
 	"k8s.io/api/core/v1"
@@ -31,26 +32,82 @@ func newServiceConverter() converter.Converter {
 	return &serviceConverter{}
 }
 
+// ClusterIP returns the ClusterIP of the Service if it's the type of
+// Services we can handle.  Otherwise, returns an empty string.
+func ClusterIP(spec *v1.ServiceSpec) string {
+	svcIP := spec.ClusterIP
+	if svcIP == "" || svcIP == v1.ClusterIPNone {
+		return ""
+	}
+	// REVISIT: IPv6 is not supported yet as MidoNet NAT Rules are
+	// IPv4 only.
+	ip := net.ParseIP(svcIP)
+	if ip == nil || ip.To4() == nil {
+		return ""
+	}
+	switch spec.Type {
+	case v1.ServiceTypeClusterIP, v1.ServiceTypeNodePort, v1.ServiceTypeLoadBalancer:
+		return svcIP
+	}
+	return ""
+}
+
 func (*serviceConverter) Convert(key converter.Key, obj interface{}, config *converter.Config) ([]converter.BackendResource, converter.SubResourceMap, error) {
 	resources := make([]converter.BackendResource, 0)
 	subs := make(converter.SubResourceMap)
-	spec := obj.(*v1.Service).Spec
-	svcIP := spec.ClusterIP
-	if spec.Type != v1.ServiceTypeClusterIP || svcIP == "" || svcIP == v1.ClusterIPNone {
+	svc := obj.(*v1.Service)
+	spec := svc.Spec
+	svcIP := ClusterIP(&spec)
+	if svcIP == "" {
 		return resources, nil, nil
 	}
+	extIPs := externalIPs(svc)
 	for _, p := range spec.Ports {
 		// Note: portKey format should be consistent with the
 		// endpoints converter so that it can find the right chain
 		// to add rules. (portChainID)
 		// NameSpace/Name/ServicePort.Name
 		portKey := fmt.Sprintf("%s/%s", key.Key(), p.Name)
-		portChainID := converter.IDForKey("ServicePort", portKey)
-		resources = append(resources, &midonet.Chain{
-			ID:       &portChainID,
-			Name:     fmt.Sprintf("KUBE-SVC-%s", portKey),
-			TenantID: config.Tenant,
-		})
+		portChainID := PortChainID(portKey)
+		hairpinChainID := HairpinChainID(portKey)
+		lbChainID := LBChainID(portKey)
+		// Note: The IDs differ from the ones used when the hairpin SNAT
+		// was applied before DNAT so that the Rules are re-created in
+		// the new order on upgrade.
+		jumpToHairpinRuleID := converter.SubID(portChainID, "Jump to Hairpin")
+		jumpToLBRuleID := converter.SubID(portChainID, "Jump to LB")
+		acceptRuleID := converter.SubID(portChainID, "Accept")
+		resources = append(resources,
+			&midonet.Chain{
+				ID:       &portChainID,
+				Name:     fmt.Sprintf("KUBE-SVC-%s", portKey),
+				TenantID: config.Tenant,
+			},
+			&midonet.Chain{
+				ID:       &hairpinChainID,
+				Name:     fmt.Sprintf("KUBE-SVC-HAIRPIN-%s", portKey),
+				TenantID: config.Tenant,
+			},
+			&midonet.Chain{
+				ID:       &lbChainID,
+				Name:     fmt.Sprintf("KUBE-SVC-LB-%s", portKey),
+				TenantID: config.Tenant,
+			},
+			// Note: MidoNet inserts a Rule at the top of the Chain
+			// unless its position is specified.  The following order
+			// results in:
+			//   1. DNAT in the LB Chain, which returns here
+			//   2. The hairpin SNAT, which needs the DNAT target
+			//   3. Accept the rest, so that the DNAT'ed traffic isn't
+			//      evaluated by the Rules for other Services
+			&midonet.Rule{
+				Parent: midonet.Parent{ID: &portChainID},
+				ID:     &acceptRuleID,
+				Type:   "accept",
+			},
+			midonet.JumpRule(&jumpToHairpinRuleID, &portChainID, &hairpinChainID),
+			midonet.JumpRule(&jumpToLBRuleID, &portChainID, &lbChainID),
+		)
 
 		var proto int
 		switch p.Protocol {
@@ -65,15 +122,68 @@ func (*serviceConverter) Convert(key converter.Key, obj interface{}, config *con
 		// Use a separate key for sub resource so that those will
 		// be deleted and re-created whenever they got changed.
 		// Note that MidoNet Rules are not updateable.
-		// The above KUBE-SVC- chain is not a part of this sub resource
-		// because we want to avoid re-creating the chain itself
-		// as it would remove rules in the chain.  (Those rules are
+		// The above chains are not a part of this sub resource
+		// because we want to avoid re-creating the chains themselves
+		// as it would remove rules in the chains.  (Those rules are
 		// managed by a separate "endpoints" controller.)
 		k := converter.Key{
 			Kind: "Service-Port",
 			Name: fmt.Sprintf("%s/%s/%d/%d", portKey, svcIP, proto, port),
 		}
 		subs[k] = &servicePort{portKey, svcIP, proto, port}
+		for _, extIP := range extIPs {
+			k := converter.Key{
+				Kind: "Service-ExternalIP",
+				Name: fmt.Sprintf("%s/%s/%d/%d", portKey, extIP, proto, port),
+			}
+			subs[k] = &servicePort{portKey, extIP, proto, port}
+		}
+		nodePort := int(p.NodePort)
+		// Note: LoadBalancer Services have NodePorts as well.
+		if spec.Type != v1.ServiceTypeClusterIP && nodePort != 0 {
+			k := converter.Key{
+				Kind: "Service-NodePort",
+				Name: fmt.Sprintf("%s/%d/%d", portKey, proto, nodePort),
+			}
+			subs[k] = &serviceNodePort{portKey, proto, nodePort}
+		}
+	}
+	for _, extIP := range extIPs {
+		k := converter.Key{
+			Kind: "Service-ExternalIP-Route",
+			Name: fmt.Sprintf("%s/%s", key.Key(), extIP),
+		}
+		subs[k] = &serviceExternalIPRoute{net.ParseIP(extIP)}
 	}
 	return resources, subs, nil
 }
+
+// externalIPs returns the addresses, other than the ClusterIP, which
+// the Service should be reachable with.  That is, the externalIPs and,
+// for LoadBalancer Services, the ingress IPs.
+func externalIPs(svc *v1.Service) []string {
+	candidates := append([]string{}, svc.Spec.ExternalIPs...)
+	if svc.Spec.Type == v1.ServiceTypeLoadBalancer {
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				candidates = append(candidates, ingress.IP)
+			}
+		}
+	}
+	ips := make([]string, 0, len(candidates))
+	seen := make(map[string]bool)
+	for _, extIP := range candidates {
+		ip := net.ParseIP(extIP)
+		if ip == nil || ip.To4() == nil {
+			log.WithField("externalIP", extIP).Warn("Ignoring non-IPv4 externalIP")
+			continue
+		}
+		// Avoid duplicate sub resources, which would have the same IDs.
+		if seen[ip.String()] {
+			continue
+		}
+		seen[ip.String()] = true
+		ips = append(ips, ip.String())
+	}
+	return ips
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+func TestExternalIPs(t *testing.T) {
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:        v1.ServiceTypeClusterIP,
+			ExternalIPs: []string{"192.0.2.1", "2001:db8::1", "bogus"},
+		},
+		Status: v1.ServiceStatus{
+			LoadBalancer: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{
+					{IP: "192.0.2.1"},
+					{IP: "198.51.100.1"},
+					{Hostname: "lb.example.com"},
+				},
+			},
+		},
+	}
+	got := externalIPs(svc)
+	want := []string{"192.0.2.1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	svc.Spec.Type = v1.ServiceTypeLoadBalancer
+	got = externalIPs(svc)
+	want = []string{"192.0.2.1", "198.51.100.1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestClusterIP(t *testing.T) {
+	cases := []struct {
+		spec v1.ServiceSpec
+		want string
+	}{
+		{v1.ServiceSpec{Type: v1.ServiceTypeClusterIP, ClusterIP: "10.96.0.1"}, "10.96.0.1"},
+		{v1.ServiceSpec{Type: v1.ServiceTypeClusterIP, ClusterIP: v1.ClusterIPNone}, ""},
+		{v1.ServiceSpec{Type: v1.ServiceTypeClusterIP, ClusterIP: "fd00::1"}, ""},
+		{v1.ServiceSpec{Type: v1.ServiceTypeExternalName, ClusterIP: "10.96.0.1"}, ""},
+	}
+	for _, tc := range cases {
+		got := ClusterIP(&tc.spec)
+		if got != tc.want {
+			t.Errorf("%v: got %v\nwant %v", tc.spec, got, tc.want)
+		}
+	}
+}
+
+func TestPortChainOrder(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "svc"},
+		Spec: v1.ServiceSpec{
+			Type:      v1.ServiceTypeClusterIP,
+			ClusterIP: "10.96.0.10",
+			Ports:     []v1.ServicePort{{Name: "http", Protocol: v1.ProtocolTCP, Port: 80}},
+		},
+	}
+	key := converter.Key{Kind: "Service", Namespace: "ns", Name: "svc"}
+	resources, _, err := newServiceConverter().Convert(key, svc, &converter.Config{})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	portChainID := PortChainID("ns/svc/http")
+	var rules []string
+	for _, res := range resources {
+		rule, ok := res.(*midonet.Rule)
+		if !ok || *rule.Parent.ID != portChainID {
+			continue
+		}
+		switch {
+		case rule.Type == "jump" && *rule.JumpChainID == LBChainID("ns/svc/http"):
+			rules = append(rules, "lb")
+		case rule.Type == "jump" && *rule.JumpChainID == HairpinChainID("ns/svc/http"):
+			rules = append(rules, "hairpin")
+		default:
+			rules = append(rules, rule.Type)
+		}
+	}
+	// Note: MidoNet inserts a Rule at the top of the Chain.  That is,
+	// the Rules are evaluated in the reverse order.
+	want := []string{"accept", "hairpin", "lb"}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("got %v\nwant %v", rules, want)
+	}
+}
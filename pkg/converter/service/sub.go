@@ -16,6 +16,8 @@
 package service
 
 import (
+	"net"
+
 	"github.com/midonet/midonet-kubernetes/pkg/converter"
 	"github.com/midonet/midonet-kubernetes/pkg/midonet"
 )
@@ -30,7 +32,7 @@ type servicePort struct {
 func (s *servicePort) Convert(key converter.Key, config *converter.Config) ([]converter.BackendResource, error) {
 	svcsChainID := converter.ServicesChainID(config)
 	jumpRuleID := converter.IDForKey("ServicePortSub", key.Key())
-	portChainID := converter.IDForKey("ServicePort", s.portKey)
+	portChainID := PortChainID(s.portKey)
 	return []converter.BackendResource{
 		&midonet.Rule{
 			Parent:       midonet.Parent{ID: &svcsChainID},
@@ -45,3 +47,55 @@ func (s *servicePort) Convert(key converter.Key, config *converter.Config) ([]co
 		},
 	}, nil
 }
+
+type serviceNodePort struct {
+	portKey  string
+	proto    int
+	nodePort int
+}
+
+func (s *serviceNodePort) Convert(key converter.Key, config *converter.Config) ([]converter.BackendResource, error) {
+	nodePortsChainID := converter.NodePortsChainID(config)
+	jumpRuleID := converter.IDForKey("ServiceNodePort", key.Key())
+	portChainID := PortChainID(s.portKey)
+	// Note: The traffic to Node addresses is dispatched to the
+	// KUBE-NODEPORTS chain by the rules maintained by the node controller.
+	// The return traffic is handled by the REV_DNAT rule in the global
+	// KUBE-PRE chain, as it is for ClusterIP.
+	return []converter.BackendResource{
+		&midonet.Rule{
+			Parent:      midonet.Parent{ID: &nodePortsChainID},
+			ID:          &jumpRuleID,
+			DLType:      0x800,
+			NWProto:     s.proto,
+			TPDst:       &midonet.PortRange{Start: s.nodePort, End: s.nodePort},
+			Type:        "jump",
+			JumpChainID: &portChainID,
+		},
+	}, nil
+}
+
+type serviceExternalIPRoute struct {
+	ip net.IP
+}
+
+func (s *serviceExternalIPRoute) Convert(key converter.Key, config *converter.Config) ([]converter.BackendResource, error) {
+	routerID := converter.ClusterRouterID(config)
+	routeID := converter.IDForKey("ServiceExternalIPRoute", key.Key())
+	// Note: The traffic to the externalIP is DNAT'ed by the inbound
+	// filter of the cluster router before the route lookup.  This route
+	// makes the router own the address so that the traffic to the ports
+	// not exposed by the Service is rejected rather than being forwarded
+	// to somewhere else, e.g. the default route.
+	return []converter.BackendResource{
+		&midonet.Route{
+			Parent:           midonet.Parent{ID: &routerID},
+			ID:               &routeID,
+			DstNetworkAddr:   s.ip,
+			DstNetworkLength: 32,
+			SrcNetworkAddr:   net.ParseIP("0.0.0.0"),
+			SrcNetworkLength: 0,
+			Type:             "Reject",
+		},
+	}, nil
+}
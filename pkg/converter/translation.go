@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	jsonpatch "github.com/evanphx/json-patch"
 	log "github.com/sirupsen/logrus"
@@ -215,6 +216,7 @@ func (u *translationUpdater) updateOne(parentRef *v1.ObjectReference, ns, name s
 	meta.SetOwnerReferences(owners)
 	meta.SetLabels(labels)
 	meta.SetFinalizers(finalizers)
+	setUpdatedAt(obj)
 	clog = clog.WithField("obj", obj)
 	newObj, err := u.client.MidonetV1().Translations(ns).Create(obj)
 	if err == nil {
@@ -239,17 +241,9 @@ func (u *translationUpdater) updateOne(parentRef *v1.ObjectReference, ns, name s
 		clog.WithError(err).Error("Get")
 		return "", err
 	}
-	oldData, err := json.Marshal(existingObj)
-	if err != nil {
-		return "", err
-	}
 	desiredObj := existingObj.DeepCopy()
 	desiredObj.Resources = obj.Resources
-	desiredData, err := json.Marshal(desiredObj)
-	if err != nil {
-		return "", err
-	}
-	patchBytes, err := jsonpatch.CreateMergePatch(oldData, desiredData)
+	patchBytes, err := createMergePatch(existingObj, desiredObj)
 	if err != nil {
 		return "", err
 	}
@@ -260,6 +254,13 @@ func (u *translationUpdater) updateOne(parentRef *v1.ObjectReference, ns, name s
 		}).Debug("Skipping no-op update of Translation")
 		return existingObj.ObjectMeta.UID, nil
 	}
+	// Note: Set the timestamp only after the above check.  Otherwise
+	// every update would look like a change.
+	setUpdatedAt(desiredObj)
+	patchBytes, err = createMergePatch(existingObj, desiredObj)
+	if err != nil {
+		return "", err
+	}
 	clog = clog.WithField("patch", string(patchBytes))
 	newObj, err = u.client.MidonetV1().Translations(ns).Patch(name, types.MergePatchType, patchBytes)
 	if err != nil {
@@ -283,6 +284,27 @@ func (u *translationUpdater) updateOne(parentRef *v1.ObjectReference, ns, name s
 	return newObj.ObjectMeta.UID, nil
 }
 
+func createMergePatch(old *mnv1.Translation, new *mnv1.Translation) ([]byte, error) {
+	oldData, err := json.Marshal(old)
+	if err != nil {
+		return nil, err
+	}
+	newData, err := json.Marshal(new)
+	if err != nil {
+		return nil, err
+	}
+	return jsonpatch.CreateMergePatch(oldData, newData)
+}
+
+// setUpdatedAt records the current time in the Translation.
+// The pusher uses it to measure the lag of pushes.
+func setUpdatedAt(tr *mnv1.Translation) {
+	if tr.ObjectMeta.Annotations == nil {
+		tr.ObjectMeta.Annotations = make(map[string]string)
+	}
+	tr.ObjectMeta.Annotations[UpdatedAtAnnotation] = time.Now().Format(time.RFC3339Nano)
+}
+
 func checkTranslationUpdate(old *mnv1.Translation, new *mnv1.Translation) {
 	clog := log.WithFields(log.Fields{
 		"old": "old",
@@ -0,0 +1,344 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package converter
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/google/uuid"
+
+	"github.com/midonet/midonet-kubernetes/pkg/config"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+// UplinkConfig describes the uplink of the cluster router, which
+// provides the external connectivity for the cluster.
+type UplinkConfig struct {
+	// The address and the subnet of the uplink Router Port.
+	Address net.IP
+	Subnet  *net.IPNet
+
+	// The next hop for the default route.  Optional if BGP is used.
+	Gateway net.IP
+
+	// The host interface to bind the uplink Router Port to.
+	HostID    *uuid.UUID
+	Interface string
+
+	// Alternatively, an existing MidoNet Router to link the uplink
+	// Router Port to.  E.g. the provider router of an OpenStack deployment.
+	// In that case, Gateway is used as the address of the peer port.
+	ProviderRouterID *uuid.UUID
+
+	// The networks which should be routed to the cluster router from
+	// outside, e.g. the LoadBalancer IP pool.  They are advertised via
+	// BGP or routed by the provider router.
+	Networks []*net.IPNet
+
+	BGPLocalAS     int
+	BGPPeerAS      int
+	BGPPeerAddress net.IP
+
+	// The Pod network.  The traffic from it is masqueraded with
+	// Address when leaving the cluster via the uplink.
+	ClusterCIDR *net.IPNet
+}
+
+func parseIPv4(s string) (net.IP, error) {
+	ip := net.ParseIP(s)
+	if ip == nil || ip.To4() == nil {
+		return nil, fmt.Errorf("Invalid IPv4 address %s", s)
+	}
+	return ip.To4(), nil
+}
+
+func parseUUID(s string) (*uuid.UUID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func newUplinkConfig(c *config.Config) (*UplinkConfig, error) {
+	if c.UplinkAddress == "" {
+		return nil, nil
+	}
+	ip, subnet, err := net.ParseCIDR(c.UplinkAddress)
+	if err != nil || ip.To4() == nil {
+		return nil, fmt.Errorf("Invalid uplink address %s", c.UplinkAddress)
+	}
+	if c.ClusterCIDR == "" {
+		return nil, fmt.Errorf("Cluster CIDR is required for the uplink")
+	}
+	_, clusterCIDR, err := net.ParseCIDR(c.ClusterCIDR)
+	if err != nil {
+		return nil, err
+	}
+	u := &UplinkConfig{
+		Address:     ip.To4(),
+		Subnet:      subnet,
+		Interface:   c.UplinkInterface,
+		BGPLocalAS:  c.UplinkBGPLocalAS,
+		BGPPeerAS:   c.UplinkBGPPeerAS,
+		ClusterCIDR: clusterCIDR,
+	}
+	if c.UplinkGateway != "" {
+		u.Gateway, err = parseIPv4(c.UplinkGateway)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range strings.Split(c.UplinkNetworks, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		u.Networks = append(u.Networks, n)
+	}
+	switch {
+	case c.UplinkProviderRouterID != "" && c.UplinkHostID != "":
+		return nil, fmt.Errorf("Specify either uplink host ID or provider router ID")
+	case c.UplinkProviderRouterID != "":
+		u.ProviderRouterID, err = parseUUID(c.UplinkProviderRouterID)
+		if err != nil {
+			return nil, err
+		}
+		if u.Gateway == nil {
+			return nil, fmt.Errorf("Uplink gateway is required for the provider router")
+		}
+	case c.UplinkHostID != "":
+		u.HostID, err = parseUUID(c.UplinkHostID)
+		if err != nil {
+			return nil, err
+		}
+		if u.Interface == "" {
+			return nil, fmt.Errorf("Uplink interface is required for the uplink host")
+		}
+	default:
+		return nil, fmt.Errorf("Either uplink host ID or provider router ID is required")
+	}
+	if c.UplinkBGPPeerAddress != "" {
+		if u.ProviderRouterID != nil {
+			return nil, fmt.Errorf("BGP is not supported for the provider router")
+		}
+		u.BGPPeerAddress, err = parseIPv4(c.UplinkBGPPeerAddress)
+		if err != nil {
+			return nil, err
+		}
+		if u.BGPLocalAS == 0 || u.BGPPeerAS == 0 {
+			return nil, fmt.Errorf("AS numbers are required for BGP")
+		}
+	} else if u.Gateway == nil {
+		return nil, fmt.Errorf("Either uplink gateway or BGP peer is required")
+	}
+	return u, nil
+}
+
+func joinCIDRs(networks []*net.IPNet) string {
+	l := make([]string, 0, len(networks))
+	for _, n := range networks {
+		l = append(l, n.String())
+	}
+	return strings.Join(l, ",")
+}
+
+func route(id, routerID, portID *uuid.UUID, dst *net.IPNet, gateway net.IP) *midonet.Route {
+	dstLen, _ := dst.Mask.Size()
+	return &midonet.Route{
+		Parent:           midonet.Parent{ID: routerID},
+		ID:               id,
+		DstNetworkAddr:   dst.IP,
+		DstNetworkLength: dstLen,
+		SrcNetworkAddr:   net.ParseIP("0.0.0.0"),
+		SrcNetworkLength: 0,
+		NextHopPort:      portID,
+		NextHopGateway:   gateway,
+		Type:             "Normal",
+	}
+}
+
+// uplinkResources returns the global resources for the uplink.
+// Note: Rules and Routes are not updateable.  The names of their
+// Translations, and thus their IDs, include the relevant configuration
+// so that they are re-created when the configuration is changed.
+func uplinkResources(config *Config) map[Key]([]BackendResource) {
+	resources := make(map[Key]([]BackendResource))
+	u := config.Uplink
+	if u == nil {
+		return resources
+	}
+	tenant := config.Tenant
+	baseID := SubID(idForTenant(tenant), "Uplink")
+	routerID := ClusterRouterID(config)
+	portID := SubID(baseID, "Port")
+	inChainID := SubID(baseID, "Inbound Chain")
+	outChainID := SubID(baseID, "Outbound Chain")
+	routerChainID := RouterChainID(config)
+	mainChainID := MainChainID(config)
+	jumpToMainRuleID := SubID(baseID, "Jump To Main")
+	kind := "midonet-global"
+	resources[Key{Kind: kind, Name: "uplink-port"}] = []BackendResource{
+		&midonet.Chain{
+			ID:       &inChainID,
+			Name:     "KUBE-UPLINK-IN",
+			TenantID: tenant,
+		},
+		&midonet.Chain{
+			ID:       &outChainID,
+			Name:     "KUBE-UPLINK-OUT",
+			TenantID: tenant,
+		},
+		&midonet.Port{
+			Parent:           midonet.Parent{ID: &routerID},
+			ID:               &portID,
+			Type:             "Router",
+			PortSubnet:       []*types.IPNet{{IP: u.Address, Mask: u.Subnet.Mask}},
+			PortMAC:          midonet.HardwareAddr(MACForKey(tenant + "/uplink")),
+			InboundFilterID:  &inChainID,
+			OutboundFilterID: &outChainID,
+		},
+		// The traffic from outside of the cluster, e.g. to Service
+		// externalIPs and NodePorts, only comes via the uplink.
+		&midonet.Rule{
+			Parent:      midonet.Parent{ID: &routerChainID},
+			ID:          &jumpToMainRuleID,
+			Type:        "jump",
+			JumpChainID: &mainChainID,
+			InPorts:     []uuid.UUID{portID},
+		},
+	}
+
+	// Masquerade the traffic from Pods leaving the cluster.
+	name := fmt.Sprintf("uplink-masquerade/%s/%s", u.ClusterCIDR, u.Address)
+	id := SubID(baseID, name)
+	snatRuleID := SubID(id, "SNAT")
+	revSNATRuleID := SubID(id, "Reverse SNAT")
+	clusterLen, _ := u.ClusterCIDR.Mask.Size()
+	resources[Key{Kind: kind, Name: name}] = []BackendResource{
+		&midonet.Rule{
+			Parent:       midonet.Parent{ID: &outChainID},
+			ID:           &snatRuleID,
+			Type:         "snat",
+			DLType:       0x800,
+			NWSrcAddress: u.ClusterCIDR.IP.String(),
+			NWSrcLength:  clusterLen,
+			NATTargets: &[]midonet.NATTarget{
+				{
+					AddressFrom: u.Address.String(),
+					AddressTo:   u.Address.String(),
+					PortFrom:    config.NATPortFrom,
+					PortTo:      config.NATPortTo,
+				},
+			},
+			FlowAction: "accept",
+		},
+		&midonet.Rule{
+			Parent:     midonet.Parent{ID: &inChainID},
+			ID:         &revSNATRuleID,
+			Type:       "rev_snat",
+			FlowAction: "continue",
+		},
+	}
+
+	name = fmt.Sprintf("uplink-routes/%s", u.Subnet)
+	if u.Gateway != nil {
+		name = fmt.Sprintf("%s/%s", name, u.Gateway)
+	}
+	id = SubID(baseID, name)
+	subnetRouteID := SubID(id, "Subnet Route")
+	routes := []BackendResource{
+		route(&subnetRouteID, &routerID, &portID, u.Subnet, nil),
+	}
+	if u.Gateway != nil {
+		defaultRouteID := SubID(id, "Default Route")
+		_, defaultDst, _ := net.ParseCIDR("0.0.0.0/0")
+		routes = append(routes, route(&defaultRouteID, &routerID, &portID, defaultDst, u.Gateway))
+	}
+	resources[Key{Kind: kind, Name: name}] = routes
+
+	if u.HostID != nil {
+		name = fmt.Sprintf("uplink-binding/%s/%s", u.HostID, u.Interface)
+		resources[Key{Kind: kind, Name: name}] = []BackendResource{
+			&midonet.HostInterfacePort{
+				Parent:        midonet.Parent{ID: u.HostID},
+				HostID:        u.HostID,
+				PortID:        &portID,
+				InterfaceName: u.Interface,
+			},
+		}
+	}
+	if u.ProviderRouterID != nil {
+		providerPortID := SubID(baseID, fmt.Sprintf("Provider Port %s", u.ProviderRouterID))
+		name = fmt.Sprintf("uplink-provider/%s", u.ProviderRouterID)
+		resources[Key{Kind: kind, Name: name}] = []BackendResource{
+			&midonet.Port{
+				Parent:     midonet.Parent{ID: u.ProviderRouterID},
+				ID:         &providerPortID,
+				Type:       "Router",
+				PortSubnet: []*types.IPNet{{IP: u.Gateway, Mask: u.Subnet.Mask}},
+				PortMAC:    midonet.HardwareAddr(MACForKey(tenant + "/uplink-provider")),
+			},
+			&midonet.PortLink{
+				Parent: midonet.Parent{ID: &portID},
+				PeerID: &providerPortID,
+			},
+		}
+		name = fmt.Sprintf("uplink-provider-routes/%s/%s/%s/%s", u.ProviderRouterID, u.Subnet, u.Address, joinCIDRs(u.Networks))
+		id = SubID(baseID, name)
+		subnetRouteID := SubID(id, "Subnet Route")
+		routes := []BackendResource{
+			route(&subnetRouteID, u.ProviderRouterID, &providerPortID, u.Subnet, nil),
+		}
+		for i, n := range u.Networks {
+			routeID := SubID(id, fmt.Sprintf("Route %d", i))
+			routes = append(routes, route(&routeID, u.ProviderRouterID, &providerPortID, n, u.Address))
+		}
+		resources[Key{Kind: kind, Name: name}] = routes
+	}
+	if u.BGPPeerAddress != nil {
+		name = fmt.Sprintf("uplink-bgp/%d/%s/%s", u.BGPPeerAS, u.BGPPeerAddress, joinCIDRs(u.Networks))
+		id = SubID(baseID, name)
+		peerID := SubID(id, "BGP Peer")
+		bgp := []BackendResource{
+			&midonet.BGPPeer{
+				Parent:   midonet.Parent{ID: &routerID},
+				ID:       &peerID,
+				ASNumber: u.BGPPeerAS,
+				Address:  u.BGPPeerAddress,
+			},
+		}
+		for i, n := range u.Networks {
+			networkID := SubID(id, fmt.Sprintf("BGP Network %d", i))
+			length, _ := n.Mask.Size()
+			bgp = append(bgp, &midonet.BGPNetwork{
+				Parent:        midonet.Parent{ID: &routerID},
+				ID:            &networkID,
+				SubnetAddress: n.IP,
+				SubnetLength:  length,
+			})
+		}
+		resources[Key{Kind: kind, Name: name}] = bgp
+	}
+	return resources
+}
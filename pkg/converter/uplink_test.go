@@ -0,0 +1,72 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package converter
+
+import (
+	"testing"
+
+	"github.com/midonet/midonet-kubernetes/pkg/config"
+)
+
+func TestNewUplinkConfig(t *testing.T) {
+	base := config.Config{
+		ClusterCIDR:   "10.1.0.0/16",
+		UplinkAddress: "192.0.2.2/24",
+		UplinkGateway: "192.0.2.1",
+		UplinkHostID:  "a2b0f0e8-1c0a-4d7e-9d6b-4c6d7b3f9a10",
+	}
+	cases := []struct {
+		name  string
+		tweak func(*config.Config)
+		valid bool
+	}{
+		{"no uplink", func(c *config.Config) { c.UplinkAddress = "" }, true},
+		{"no interface", func(c *config.Config) {}, false},
+		{"host interface", func(c *config.Config) { c.UplinkInterface = "eth1" }, true},
+		{"no cluster cidr", func(c *config.Config) {
+			c.UplinkInterface = "eth1"
+			c.ClusterCIDR = ""
+		}, false},
+		{"provider router", func(c *config.Config) {
+			c.UplinkHostID = ""
+			c.UplinkProviderRouterID = "0e6f2a36-6b8a-4f5f-8f56-4b1d0f0c2e71"
+		}, true},
+		{"both", func(c *config.Config) {
+			c.UplinkInterface = "eth1"
+			c.UplinkProviderRouterID = "0e6f2a36-6b8a-4f5f-8f56-4b1d0f0c2e71"
+		}, false},
+		{"bgp", func(c *config.Config) {
+			c.UplinkInterface = "eth1"
+			c.UplinkGateway = ""
+			c.UplinkBGPPeerAddress = "192.0.2.1"
+			c.UplinkBGPLocalAS = 64512
+			c.UplinkBGPPeerAS = 64513
+		}, true},
+		{"bgp without as", func(c *config.Config) {
+			c.UplinkInterface = "eth1"
+			c.UplinkGateway = ""
+			c.UplinkBGPPeerAddress = "192.0.2.1"
+		}, false},
+	}
+	for _, tc := range cases {
+		c := base
+		tc.tweak(&c)
+		_, err := newUplinkConfig(&c)
+		if (err == nil) != tc.valid {
+			t.Errorf("%s: got %v\nwant valid=%v", tc.name, err, tc.valid)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package health
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Check returns an error if the component is not healthy.
+// The returned string is an optional detail to report.
+type Check func() (string, error)
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checks is a set of liveness and readiness checks.
+type Checks struct {
+	mu        sync.Mutex
+	liveness  []namedCheck
+	readiness []namedCheck
+}
+
+// NewChecks creates an empty Checks.  Without checks, both of
+// the endpoints report success.
+func NewChecks() *Checks {
+	return &Checks{}
+}
+
+// AddLivenessCheck adds a check to /healthz.
+// A failure means that the process should be restarted.
+func (h *Checks) AddLivenessCheck(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness = append(h.liveness, namedCheck{name, check})
+}
+
+// AddReadinessCheck adds a check to /readyz.
+// A failure means that the process is not ready to do its job.
+// Note: Liveness checks are included in /readyz as well.
+func (h *Checks) AddReadinessCheck(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness = append(h.readiness, namedCheck{name, check})
+}
+
+// Install registers /healthz and /readyz handlers to the mux.
+func (h *Checks) Install(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, h.checks(false))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, h.checks(true))
+	})
+}
+
+func (h *Checks) checks(readiness bool) []namedCheck {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	checks := append([]namedCheck{}, h.liveness...)
+	if readiness {
+		checks = append(checks, h.readiness...)
+	}
+	return checks
+}
+
+// serve runs the checks and writes the results in a format similar to
+// kube-apiserver's one.
+//
+//	[+]informer-sync ok
+//	[-]midonet-api failed: Post http://...: connection refused
+func (h *Checks) serve(w http.ResponseWriter, checks []namedCheck) {
+	var buf bytes.Buffer
+	failed := false
+	for _, c := range checks {
+		detail, err := c.check()
+		if err != nil {
+			failed = true
+			log.WithError(err).WithField("check", c.name).Info("Health check failed")
+			fmt.Fprintf(&buf, "[-]%s failed: %v\n", c.name, err)
+			continue
+		}
+		if detail != "" {
+			fmt.Fprintf(&buf, "[+]%s ok: %s\n", c.name, detail)
+		} else {
+			fmt.Fprintf(&buf, "[+]%s ok\n", c.name)
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if failed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(&buf, "unhealthy\n")
+	} else {
+		fmt.Fprint(&buf, "ok\n")
+	}
+	w.Write(buf.Bytes())
+}
+
+// Flag is a Check which succeeds once it's set.
+type Flag struct {
+	mu     sync.Mutex
+	set    bool
+	reason string
+}
+
+// NewFlag creates a Flag.  The reason is reported while it's not set.
+func NewFlag(reason string) *Flag {
+	return &Flag{reason: reason}
+}
+
+// Set marks the Flag.
+func (f *Flag) Set() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.set = true
+}
+
+// IsSet returns true if the Flag is set.
+func (f *Flag) IsSet() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.set
+}
+
+// Check implements Check.
+func (f *Flag) Check() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.set {
+		return "", fmt.Errorf("%s", f.reason)
+	}
+	return "", nil
+}
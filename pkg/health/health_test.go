@@ -0,0 +1,61 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, mux *http.ServeMux, path string) (int, string) {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w.Code, w.Body.String()
+}
+
+func TestChecks(t *testing.T) {
+	h := NewChecks()
+	mux := http.NewServeMux()
+	h.Install(mux)
+	synced := NewFlag("not synced yet")
+	h.AddLivenessCheck("alive", func() (string, error) { return "", nil })
+	h.AddReadinessCheck("synced", synced.Check)
+	h.AddReadinessCheck("leader", func() (string, error) { return "standby", nil })
+
+	code, body := get(t, mux, "/healthz")
+	if code != http.StatusOK {
+		t.Errorf("got %d %s\nwant 200", code, body)
+	}
+	code, body = get(t, mux, "/readyz")
+	if code != http.StatusServiceUnavailable || !strings.Contains(body, "[-]synced failed: not synced yet") {
+		t.Errorf("got %d %s\nwant 503", code, body)
+	}
+	synced.Set()
+	code, body = get(t, mux, "/readyz")
+	expected := "[+]alive ok\n[+]synced ok\n[+]leader ok: standby\nok\n"
+	if code != http.StatusOK || body != expected {
+		t.Errorf("got %d %s\nwant 200 %s", code, body, expected)
+	}
+
+	h.AddLivenessCheck("dead", func() (string, error) { return "", fmt.Errorf("dead") })
+	code, body = get(t, mux, "/healthz")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("got %d %s\nwant 503", code, body)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package loadbalancer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Allocator allocates VIPs for LoadBalancer Services.
+// Services are identified by their keys. (Namespace/Name)
+type Allocator interface {
+	// Allocate returns an address for the Service.  It keeps returning
+	// the same address for the Service until it's released.
+	Allocate(key string) (net.IP, error)
+
+	// Reserve marks the given address as used by the Service.
+	// The address previously used by the Service, if any, is released.
+	Reserve(key string, ip net.IP) error
+
+	// Release releases the address used by the Service, if any.
+	Release(key string)
+
+	// Owns returns true if the given address is managed by the Allocator.
+	Owns(ip net.IP) bool
+}
+
+type ipRange struct {
+	first uint32
+	last  uint32
+}
+
+type poolAllocator struct {
+	lock   sync.Mutex
+	ranges []ipRange
+	used   map[uint32]string
+	byKey  map[string]uint32
+}
+
+// NewPoolAllocator creates an Allocator which allocates addresses from
+// the given pool.  The pool is a comma separated list of IPv4 CIDRs
+// (e.g. "192.0.2.0/24") and ranges (e.g. "192.0.2.10-192.0.2.20").
+// An empty pool is valid but no addresses can be allocated from it.
+func NewPoolAllocator(pool string) (Allocator, error) {
+	ranges := make([]ipRange, 0)
+	for _, s := range strings.Split(pool, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		r, err := parseRange(s)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return &poolAllocator{
+		ranges: ranges,
+		used:   make(map[uint32]string),
+		byKey:  make(map[string]uint32),
+	}, nil
+}
+
+func parseIPv4(s string) (uint32, error) {
+	ip := net.ParseIP(s)
+	if ip == nil || ip.To4() == nil {
+		return 0, fmt.Errorf("Invalid IPv4 address %s", s)
+	}
+	return binary.BigEndian.Uint32(ip.To4()), nil
+}
+
+func parseRange(s string) (ipRange, error) {
+	if strings.Contains(s, "/") {
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return ipRange{}, err
+		}
+		if ipnet.IP.To4() == nil {
+			return ipRange{}, fmt.Errorf("Invalid IPv4 CIDR %s", s)
+		}
+		first := binary.BigEndian.Uint32(ipnet.IP.To4())
+		ones, bits := ipnet.Mask.Size()
+		last := first | (1<<uint(bits-ones) - 1)
+		// Exclude the network and broadcast addresses.
+		if bits-ones > 1 {
+			first++
+			last--
+		}
+		return ipRange{first, last}, nil
+	}
+	fields := strings.SplitN(s, "-", 2)
+	first, err := parseIPv4(strings.TrimSpace(fields[0]))
+	if err != nil {
+		return ipRange{}, err
+	}
+	last := first
+	if len(fields) == 2 {
+		last, err = parseIPv4(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return ipRange{}, err
+		}
+	}
+	if last < first {
+		return ipRange{}, fmt.Errorf("Invalid range %s", s)
+	}
+	return ipRange{first, last}, nil
+}
+
+func toIP(n uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+func (a *poolAllocator) owns(n uint32) bool {
+	for _, r := range a.ranges {
+		if r.first <= n && n <= r.last {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *poolAllocator) Owns(ip net.IP) bool {
+	if ip.To4() == nil {
+		return false
+	}
+	return a.owns(binary.BigEndian.Uint32(ip.To4()))
+}
+
+func (a *poolAllocator) Allocate(key string) (net.IP, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if n, ok := a.byKey[key]; ok {
+		return toIP(n), nil
+	}
+	for _, r := range a.ranges {
+		// Note: Loop with uint64 to avoid overflow at 255.255.255.255
+		for n := uint64(r.first); n <= uint64(r.last); n++ {
+			if _, ok := a.used[uint32(n)]; ok {
+				continue
+			}
+			a.used[uint32(n)] = key
+			a.byKey[key] = uint32(n)
+			return toIP(uint32(n)), nil
+		}
+	}
+	return nil, fmt.Errorf("No free address in the LoadBalancer IP pool")
+}
+
+func (a *poolAllocator) Reserve(key string, ip net.IP) error {
+	if !a.Owns(ip) {
+		return fmt.Errorf("Address %s is not in the LoadBalancer IP pool", ip)
+	}
+	n := binary.BigEndian.Uint32(ip.To4())
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if owner, ok := a.used[n]; ok {
+		if owner == key {
+			return nil
+		}
+		return fmt.Errorf("Address %s is already used by %s", ip, owner)
+	}
+	a.release(key)
+	a.used[n] = key
+	a.byKey[key] = n
+	return nil
+}
+
+func (a *poolAllocator) release(key string) {
+	n, ok := a.byKey[key]
+	if !ok {
+		return
+	}
+	delete(a.byKey, key)
+	delete(a.used, n)
+}
+
+func (a *poolAllocator) Release(key string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.release(key)
+}
@@ -0,0 +1,76 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package loadbalancer
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPoolAllocator(t *testing.T) {
+	a, err := NewPoolAllocator("192.0.2.0/30, 198.51.100.10-198.51.100.10")
+	if err != nil {
+		t.Fatalf("NewPoolAllocator: %v", err)
+	}
+	cases := []struct {
+		key  string
+		want string
+	}{
+		{"ns/a", "192.0.2.1"},
+		{"ns/b", "192.0.2.2"},
+		{"ns/a", "192.0.2.1"},
+		{"ns/c", "198.51.100.10"},
+	}
+	for _, tc := range cases {
+		ip, err := a.Allocate(tc.key)
+		if err != nil {
+			t.Fatalf("Allocate(%s): %v", tc.key, err)
+		}
+		if ip.String() != tc.want {
+			t.Errorf("Allocate(%s): got %v\nwant %v", tc.key, ip, tc.want)
+		}
+	}
+	if _, err := a.Allocate("ns/d"); err == nil {
+		t.Errorf("Allocate succeeded on an exhausted pool")
+	}
+	if err := a.Reserve("ns/d", net.ParseIP("192.0.2.2")); err == nil {
+		t.Errorf("Reserve succeeded for an address in use")
+	}
+	if err := a.Reserve("ns/d", net.ParseIP("203.0.113.1")); err == nil {
+		t.Errorf("Reserve succeeded for an address out of the pool")
+	}
+	a.Release("ns/b")
+	if err := a.Reserve("ns/d", net.ParseIP("192.0.2.2")); err != nil {
+		t.Errorf("Reserve: %v", err)
+	}
+	// Reserving another address releases the previous one.
+	a.Release("ns/c")
+	if err := a.Reserve("ns/d", net.ParseIP("198.51.100.10")); err != nil {
+		t.Errorf("Reserve: %v", err)
+	}
+	ip, err := a.Allocate("ns/e")
+	if err != nil || ip.String() != "192.0.2.2" {
+		t.Errorf("Allocate(ns/e): got %v, %v\nwant 192.0.2.2", ip, err)
+	}
+}
+
+func TestNewPoolAllocatorInvalid(t *testing.T) {
+	for _, pool := range []string{"bogus", "2001:db8::/64", "192.0.2.10-192.0.2.1"} {
+		if _, err := NewPoolAllocator(pool); err == nil {
+			t.Errorf("NewPoolAllocator(%q) succeeded", pool)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package loadbalancer
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/midonet/midonet-kubernetes/pkg/k8s"
+)
+
+type loadBalancerHandler struct {
+	kc        *kubernetes.Clientset
+	lister    listers.ServiceLister
+	recorder  record.EventRecorder
+	allocator Allocator
+	once      sync.Once
+}
+
+func newHandler(kc *kubernetes.Clientset, lister listers.ServiceLister, recorder record.EventRecorder, allocator Allocator) *loadBalancerHandler {
+	return &loadBalancerHandler{
+		kc:        kc,
+		lister:    lister,
+		recorder:  recorder,
+		allocator: allocator,
+	}
+}
+
+// currentIP returns the ingress address of the Service which is
+// managed by our allocator, if any.
+func (h *loadBalancerHandler) currentIP(svc *v1.Service) net.IP {
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		ip := net.ParseIP(ingress.IP)
+		if ip != nil && h.allocator.Owns(ip) {
+			return ip
+		}
+	}
+	return nil
+}
+
+// reserveExisting reserves the addresses already assigned to Services,
+// e.g. by the previous incarnation of this controller, so that we don't
+// allocate them to other Services.
+// Note: This relies on the fact that the informer cache has been synced
+// before the controller starts processing the queue.
+func (h *loadBalancerHandler) reserveExisting() {
+	svcs, err := h.lister.List(labels.Everything())
+	if err != nil {
+		log.WithError(err).Fatal("Failed to list Services")
+	}
+	for _, svc := range svcs {
+		if svc.Spec.Type != v1.ServiceTypeLoadBalancer {
+			continue
+		}
+		ip := h.currentIP(svc)
+		if ip == nil {
+			continue
+		}
+		key, err := cache.MetaNamespaceKeyFunc(svc)
+		if err != nil {
+			log.WithError(err).Fatal("MetaNamespaceKeyFunc")
+		}
+		err = h.allocator.Reserve(key, ip)
+		if err != nil {
+			log.WithError(err).WithField("service", key).Warn("Failed to reserve the existing LoadBalancer IP")
+		}
+	}
+}
+
+func (h *loadBalancerHandler) allocate(key string, svc *v1.Service, current net.IP) (net.IP, error) {
+	requested := svc.Spec.LoadBalancerIP
+	if requested != "" {
+		ip := net.ParseIP(requested)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid loadBalancerIP %s", requested)
+		}
+		return ip, h.allocator.Reserve(key, ip)
+	}
+	if current != nil {
+		return current, h.allocator.Reserve(key, current)
+	}
+	return h.allocator.Allocate(key)
+}
+
+func (h *loadBalancerHandler) Update(key string, gvk schema.GroupVersionKind, obj interface{}) error {
+	h.once.Do(h.reserveExisting)
+	svc := obj.(*v1.Service)
+	clog := log.WithFields(log.Fields{
+		"service": key,
+	})
+	current := h.currentIP(svc)
+	if svc.Spec.Type != v1.ServiceTypeLoadBalancer {
+		h.allocator.Release(key)
+		if current == nil {
+			/* nothing to do */
+			return nil
+		}
+		clog.WithField("ip", current).Info("Releasing LoadBalancer IP")
+		return h.updateIngress(svc, nil)
+	}
+	ref, err := k8s.GetReferenceForEvent(svc)
+	if err != nil {
+		return err
+	}
+	ip, err := h.allocate(key, svc, current)
+	if err != nil {
+		h.recorder.Eventf(ref, v1.EventTypeWarning, "LoadBalancerIPAllocationFailed", "Failed to allocate LoadBalancer IP: %v", err)
+		return err
+	}
+	if ip.Equal(current) {
+		/* nothing to do */
+		return nil
+	}
+	clog.WithField("ip", ip).Info("Allocated LoadBalancer IP")
+	err = h.updateIngress(svc, ip)
+	if err != nil {
+		return err
+	}
+	h.recorder.Eventf(ref, v1.EventTypeNormal, "LoadBalancerIPAllocated", "Allocated LoadBalancer IP %s", ip)
+	return nil
+}
+
+// updateIngress replaces the ingress address managed by us with
+// the given one.  A nil ip means to remove it.
+func (h *loadBalancerHandler) updateIngress(svc *v1.Service, ip net.IP) error {
+	new := svc.DeepCopy()
+	ingress := make([]v1.LoadBalancerIngress, 0)
+	if ip != nil {
+		ingress = append(ingress, v1.LoadBalancerIngress{IP: ip.String()})
+	}
+	for _, i := range svc.Status.LoadBalancer.Ingress {
+		old := net.ParseIP(i.IP)
+		if old != nil && h.allocator.Owns(old) {
+			continue
+		}
+		ingress = append(ingress, i)
+	}
+	new.Status.LoadBalancer.Ingress = ingress
+	_, err := h.kc.CoreV1().Services(svc.ObjectMeta.Namespace).UpdateStatus(new)
+	return err
+}
+
+func (h *loadBalancerHandler) Delete(key string) error {
+	h.once.Do(h.reserveExisting)
+	h.allocator.Release(key)
+	return nil
+}
@@ -0,0 +1,364 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package midonet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// tokenRefreshMargin is how long before the expiration a token is
+// refreshed.  Tokens with a shorter lifetime are refreshed at the half
+// of the lifetime.
+const tokenRefreshMargin = time.Minute
+
+// Authenticator obtains tokens for MidoNet API.
+// An Authenticator is shared by the goroutines using a Client and thus
+// should be goroutine-safe.
+type Authenticator interface {
+	// Token returns the token to use for a request.  It can be ""
+	// for an anonymous request.  It should refresh the token when it's
+	// about to expire.
+	Token(ctx context.Context) (string, error)
+
+	// Refresh obtains a new token.  Client calls it with the token
+	// MidoNet API rejected.  If the token has already been replaced,
+	// e.g. by another goroutine which got the same rejection,
+	// the current token should be returned instead.
+	Refresh(ctx context.Context, rejected string) (string, error)
+}
+
+// requestFunc executes an HTTP request and returns the response and
+// its body.  See Client.executeRequest.
+type requestFunc func(req *http.Request) (*http.Response, string, error)
+
+func newAuthenticator(config *Config, do requestFunc) Authenticator {
+	switch config.auth {
+	case "keystone":
+		return &keystoneAuthenticator{config: config, do: do}
+	case "token":
+		return &staticAuthenticator{token: config.token}
+	default:
+		return &midonetAuthenticator{config: config, do: do}
+	}
+}
+
+// parseExpires parses the expiration time of a token.  The zero Time
+// is returned if it's unknown.
+func parseExpires(s string) time.Time {
+	for _, layout := range []string{time.RFC3339, time.RFC1123} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t
+		}
+	}
+	if s != "" {
+		log.WithField("expires", s).Debug("Unknown token expiration format")
+	}
+	return time.Time{}
+}
+
+// refreshTime returns when a token expiring at the given time should be
+// refreshed.  The zero Time means never.
+func refreshTime(expires time.Time) time.Time {
+	if expires.IsZero() {
+		return expires
+	}
+	margin := tokenRefreshMargin
+	if lifetime := time.Until(expires); lifetime < 2*margin {
+		margin = lifetime / 2
+	}
+	return expires.Add(-margin)
+}
+
+func expiring(refreshAt time.Time) bool {
+	return !refreshAt.IsZero() && time.Now().After(refreshAt)
+}
+
+// fetchFunc obtains a new token and its expiration time.
+type fetchFunc func(ctx context.Context) (string, time.Time, error)
+
+// tokenFlight is an in-flight fetch of a token.
+type tokenFlight struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// tokenCache holds a token shared by concurrent requests.
+// Refreshes are single-flight; the goroutines which want a new token
+// at the same time wait for a single fetch and share its result.
+type tokenCache struct {
+	mu        sync.Mutex
+	token     string
+	refreshAt time.Time
+	flight    *tokenFlight
+}
+
+// current returns the token and whether it should be refreshed.
+func (c *tokenCache) current() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token, expiring(c.refreshAt)
+}
+
+// refresh replaces the given token with a new one obtained with fetch,
+// unless it has already been replaced.
+// Note: If the goroutine doing the fetch gives up because of its
+// context, the waiting ones get the same error and can retry.
+func (c *tokenCache) refresh(ctx context.Context, old string, fetch fetchFunc) (string, error) {
+	c.mu.Lock()
+	if c.token != old {
+		token := c.token
+		c.mu.Unlock()
+		return token, nil
+	}
+	if f := c.flight; f != nil {
+		c.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.token, f.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	f := &tokenFlight{done: make(chan struct{})}
+	c.flight = f
+	c.mu.Unlock()
+
+	token, expires, err := fetch(ctx)
+
+	c.mu.Lock()
+	if err == nil {
+		c.token = token
+		c.refreshAt = refreshTime(expires)
+	}
+	c.flight = nil
+	c.mu.Unlock()
+	f.token, f.err = token, err
+	close(f.done)
+	return token, err
+}
+
+// midonetAuthenticator logs in to MidoNet API with basic auth.
+//  https://docs.midonet.org/docs/latest-en/rest-api/content/authentication-authorization.html
+type midonetAuthenticator struct {
+	config *Config
+	do     requestFunc
+	cache  tokenCache
+}
+
+type tokenInfo struct {
+	Key     string `json:"key"`
+	Expires string `json:"expires"`
+}
+
+// Token returns the current token.
+// Note: The first request is made without a token, so that MidoNet API
+// without authentication doesn't need a login.
+func (a *midonetAuthenticator) Token(ctx context.Context) (string, error) {
+	token, stale := a.cache.current()
+	if token != "" && stale {
+		return a.cache.refresh(ctx, token, a.login)
+	}
+	return token, nil
+}
+
+func (a *midonetAuthenticator) Refresh(ctx context.Context, rejected string) (string, error) {
+	return a.cache.refresh(ctx, rejected, a.login)
+}
+
+func (a *midonetAuthenticator) login(ctx context.Context) (string, time.Time, error) {
+	var zero time.Time
+	req, err := http.NewRequest("POST", a.config.api+"/login", nil)
+	if err != nil {
+		return "", zero, err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(a.config.username, a.config.password)
+	req.Header.Add("X-Auth-Project", a.config.project)
+	resp, body, err := a.do(req)
+	if err != nil {
+		return "", zero, err
+	}
+	if resp.StatusCode/100 != 2 {
+		log.WithField("statusCode", resp.StatusCode).Error("Login failure")
+		return "", zero, newError("POST", "/login", resp.StatusCode, body)
+	}
+	info := &tokenInfo{}
+	err = json.NewDecoder(strings.NewReader(body)).Decode(info)
+	if err != nil {
+		return "", zero, err
+	}
+	log.WithField("expires", info.Expires).Info("login succeeded.")
+	return info.Key, parseExpires(info.Expires), nil
+}
+
+// keystoneAuthenticator obtains Keystone v3 tokens with a password or
+// an application credential.
+//  https://developer.openstack.org/api-ref/identity/v3/#password-authentication-with-scoped-authorization
+type keystoneAuthenticator struct {
+	config *Config
+	do     requestFunc
+	cache  tokenCache
+}
+
+type keystoneName struct {
+	Name   string        `json:"name"`
+	Domain *keystoneName `json:"domain,omitempty"`
+}
+
+type keystoneUser struct {
+	Name     string       `json:"name"`
+	Domain   keystoneName `json:"domain"`
+	Password string       `json:"password"`
+}
+
+type keystonePassword struct {
+	User keystoneUser `json:"user"`
+}
+
+type keystoneAppCredential struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+type keystoneIdentity struct {
+	Methods               []string               `json:"methods"`
+	Password              *keystonePassword      `json:"password,omitempty"`
+	ApplicationCredential *keystoneAppCredential `json:"application_credential,omitempty"`
+}
+
+type keystoneScope struct {
+	Project keystoneName `json:"project"`
+}
+
+type keystoneAuth struct {
+	Identity keystoneIdentity `json:"identity"`
+	Scope    *keystoneScope   `json:"scope,omitempty"`
+}
+
+type keystoneAuthRequest struct {
+	Auth keystoneAuth `json:"auth"`
+}
+
+type keystoneAuthResponse struct {
+	Token struct {
+		ExpiresAt string `json:"expires_at"`
+	} `json:"token"`
+}
+
+func (a *keystoneAuthenticator) authRequest() *keystoneAuthRequest {
+	c := a.config.keystone
+	r := &keystoneAuthRequest{}
+	id := &r.Auth.Identity
+	if c.appCredentialID != "" {
+		// Note: An application credential is scoped by itself.
+		id.Methods = []string{"application_credential"}
+		id.ApplicationCredential = &keystoneAppCredential{
+			ID:     c.appCredentialID,
+			Secret: c.appCredentialSecret,
+		}
+		return r
+	}
+	id.Methods = []string{"password"}
+	id.Password = &keystonePassword{
+		User: keystoneUser{
+			Name:     a.config.username,
+			Domain:   keystoneName{Name: c.userDomain},
+			Password: a.config.password,
+		},
+	}
+	if a.config.project != "" {
+		r.Auth.Scope = &keystoneScope{
+			Project: keystoneName{
+				Name:   a.config.project,
+				Domain: &keystoneName{Name: c.projectDomain},
+			},
+		}
+	}
+	return r
+}
+
+// Token returns the current token, obtaining one if necessary.
+func (a *keystoneAuthenticator) Token(ctx context.Context) (string, error) {
+	token, stale := a.cache.current()
+	if token == "" || stale {
+		return a.cache.refresh(ctx, token, a.authenticate)
+	}
+	return token, nil
+}
+
+func (a *keystoneAuthenticator) Refresh(ctx context.Context, rejected string) (string, error) {
+	return a.cache.refresh(ctx, rejected, a.authenticate)
+}
+
+func (a *keystoneAuthenticator) authenticate(ctx context.Context) (string, time.Time, error) {
+	var zero time.Time
+	path := "/auth/tokens"
+	data, err := json.Marshal(a.authRequest())
+	if err != nil {
+		return "", zero, err
+	}
+	req, err := http.NewRequest("POST", a.config.keystone.url+path, bytes.NewReader(data))
+	if err != nil {
+		return "", zero, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("Content-Type", "application/json")
+	resp, body, err := a.do(req)
+	if err != nil {
+		return "", zero, err
+	}
+	if resp.StatusCode/100 != 2 {
+		log.WithField("statusCode", resp.StatusCode).Error("Keystone authentication failure")
+		return "", zero, newError("POST", path, resp.StatusCode, body)
+	}
+	token := resp.Header.Get("X-Subject-Token")
+	if token == "" {
+		return "", zero, fmt.Errorf("Keystone returned no X-Subject-Token")
+	}
+	info := &keystoneAuthResponse{}
+	err = json.NewDecoder(strings.NewReader(body)).Decode(info)
+	if err != nil {
+		return "", zero, err
+	}
+	log.WithField("expires", info.Token.ExpiresAt).Info("Keystone authentication succeeded.")
+	return token, parseExpires(info.Token.ExpiresAt), nil
+}
+
+// staticAuthenticator uses the given token as it is.
+type staticAuthenticator struct {
+	token string
+}
+
+func (a *staticAuthenticator) Token(ctx context.Context) (string, error) {
+	return a.token, nil
+}
+
+// Refresh returns the same token.  The retried request will fail with
+// 401 again.
+func (a *staticAuthenticator) Refresh(ctx context.Context, rejected string) (string, error) {
+	return a.token, nil
+}
@@ -0,0 +1,308 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package midonet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/midonet/midonet-kubernetes/pkg/config"
+)
+
+// newKeystone returns a fake Keystone which issues tokens valid for
+// the given duration, and the list of the requests it received.
+func newKeystone(lifetime time.Duration) (*httptest.Server, *[]*keystoneAuthRequest) {
+	var mu sync.Mutex
+	var requests []*keystoneAuthRequest
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method != "POST" || r.URL.Path != "/v3/auth/tokens" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		req := &keystoneAuthRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, req)
+		w.Header().Set("X-Subject-Token", fmt.Sprintf("token-%d", len(requests)))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": {"expires_at": %q}}`, time.Now().Add(lifetime).UTC().Format(time.RFC3339Nano))
+	}))
+	return s, &requests
+}
+
+// newAPI returns a fake MidoNet API which accepts any of the given
+// tokens, and the list of the tokens it received.
+func newAPI(tokens ...string) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	var received []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		token := r.Header.Get("X-Auth-Token")
+		received = append(received, token)
+		for _, t := range tokens {
+			if token == t {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	return s, &received
+}
+
+func TestKeystoneAuth(t *testing.T) {
+	keystone, requests := newKeystone(time.Hour)
+	defer keystone.Close()
+	api, received := newAPI("token-1", "token-2")
+	defer api.Close()
+	c := NewClient(NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI:            api.URL,
+		MidoNetUserName:       "admin",
+		MidoNetPassword:       "secret",
+		MidoNetProject:        "admin",
+		MidoNetAuth:           "keystone",
+		KeystoneURL:           keystone.URL + "/v3/",
+		KeystoneUserDomain:    "Default",
+		KeystoneProjectDomain: "Default",
+	}))
+	for i := 0; i < 2; i++ {
+		if err := c.Ping(context.Background()); err != nil {
+			t.Fatalf("Ping: %v", err)
+		}
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("got %d requests to Keystone\nwant 1", len(*requests))
+	}
+	req := (*requests)[0]
+	expected := &keystoneAuthRequest{
+		Auth: keystoneAuth{
+			Identity: keystoneIdentity{
+				Methods: []string{"password"},
+				Password: &keystonePassword{
+					User: keystoneUser{
+						Name:     "admin",
+						Domain:   keystoneName{Name: "Default"},
+						Password: "secret",
+					},
+				},
+			},
+			Scope: &keystoneScope{
+				Project: keystoneName{
+					Name:   "admin",
+					Domain: &keystoneName{Name: "Default"},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(req, expected) {
+		t.Errorf("got %+v\nwant %+v", req, expected)
+	}
+	if !reflect.DeepEqual(*received, []string{"token-1", "token-1"}) {
+		t.Errorf("got %v\nwant the first token for both requests", *received)
+	}
+}
+
+func TestKeystoneAuthRefresh(t *testing.T) {
+	// Short-lived tokens are refreshed at the half of the lifetime.
+	keystone, requests := newKeystone(200 * time.Millisecond)
+	defer keystone.Close()
+	api, received := newAPI("token-2", "token-3")
+	defer api.Close()
+	c := NewClient(NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI:                          api.URL,
+		MidoNetAuth:                         "keystone",
+		KeystoneURL:                         keystone.URL + "/v3",
+		KeystoneApplicationCredentialID:     "id",
+		KeystoneApplicationCredentialSecret: "secret",
+	}))
+	// token-1 is rejected and refreshed with token-2
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	// token-2 is about to expire and refreshed with token-3
+	time.Sleep(150 * time.Millisecond)
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if !reflect.DeepEqual(*received, []string{"token-1", "token-2", "token-3"}) {
+		t.Errorf("got %v", *received)
+	}
+	cred := (*requests)[0].Auth.Identity.ApplicationCredential
+	if cred == nil || cred.ID != "id" || cred.Secret != "secret" {
+		t.Errorf("got %+v\nwant the application credential", cred)
+	}
+	if (*requests)[0].Auth.Scope != nil {
+		t.Errorf("got %+v\nwant no scope", (*requests)[0].Auth.Scope)
+	}
+}
+
+func TestKeystoneAuthConcurrent(t *testing.T) {
+	keystone, requests := newKeystone(time.Hour)
+	defer keystone.Close()
+	api, received := newAPI("token-2")
+	defer api.Close()
+	c := NewClient(NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI:                          api.URL,
+		MidoNetAuth:                         "keystone",
+		KeystoneURL:                         keystone.URL + "/v3",
+		KeystoneApplicationCredentialID:     "id",
+		KeystoneApplicationCredentialSecret: "secret",
+	}))
+	// All the goroutines share the first token and, after it's
+	// rejected, the second one.
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.Ping(context.Background())
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Ping: %v", err)
+		}
+	}
+	if len(*requests) != 2 {
+		t.Errorf("got %d requests to Keystone\nwant 2", len(*requests))
+	}
+	if len(*received) != 2*n {
+		t.Errorf("got %d requests to MidoNet API\nwant %d", len(*received), 2*n)
+	}
+}
+
+func TestTokenCacheSingleFlight(t *testing.T) {
+	var mu sync.Mutex
+	fetched := 0
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) (string, time.Time, error) {
+		mu.Lock()
+		fetched++
+		token := fmt.Sprintf("token-%d", fetched)
+		mu.Unlock()
+		<-release
+		return token, time.Now().Add(time.Hour), nil
+	}
+	var c tokenCache
+	const n = 10
+	var wg sync.WaitGroup
+	tokens := make(chan string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := c.refresh(context.Background(), "", fetch)
+			if err != nil {
+				t.Errorf("refresh: %v", err)
+			}
+			tokens <- token
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(tokens)
+	for token := range tokens {
+		if token != "token-1" {
+			t.Errorf("got %q\nwant token-1", token)
+		}
+	}
+	if fetched != 1 {
+		t.Errorf("got %d fetches\nwant 1", fetched)
+	}
+}
+
+func TestKeystoneAuthFailure(t *testing.T) {
+	keystone := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer keystone.Close()
+	api, received := newAPI()
+	defer api.Close()
+	c := NewClient(NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI:  api.URL,
+		MidoNetAuth: "keystone",
+		KeystoneURL: keystone.URL,
+	}))
+	if err := c.Ping(context.Background()); !IsUnauthorized(err) {
+		t.Errorf("got %v\nwant Unauthorized", err)
+	}
+	if len(*received) != 0 {
+		t.Errorf("got %v\nwant no requests to MidoNet API", *received)
+	}
+}
+
+func TestStaticToken(t *testing.T) {
+	api, received := newAPI("static")
+	defer api.Close()
+	c := NewClient(NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI:   api.URL,
+		MidoNetAuth:  "token",
+		MidoNetToken: "static",
+	}))
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if !reflect.DeepEqual(*received, []string{"static"}) {
+		t.Errorf("got %v", *received)
+	}
+}
+
+func TestParseExpires(t *testing.T) {
+	expected := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+	for _, s := range []string{
+		"2018-10-01T12:00:00Z",
+		"2018-10-01T12:00:00.000000Z",
+		"Mon, 01 Oct 2018 12:00:00 UTC",
+	} {
+		if e := parseExpires(s); !e.Equal(expected) {
+			t.Errorf("%q: got %v\nwant %v", s, e, expected)
+		}
+	}
+	if e := parseExpires(""); !e.IsZero() {
+		t.Errorf("got %v\nwant zero", e)
+	}
+}
+
+func TestRefreshTime(t *testing.T) {
+	if r := refreshTime(time.Time{}); !r.IsZero() {
+		t.Errorf("got %v\nwant zero", r)
+	}
+	expires := time.Now().Add(time.Hour)
+	if r := refreshTime(expires); !r.Equal(expires.Add(-tokenRefreshMargin)) {
+		t.Errorf("got %v\nwant %v", r, expires.Add(-tokenRefreshMargin))
+	}
+	expires = time.Now().Add(tokenRefreshMargin)
+	if r := refreshTime(expires); !r.Before(expires) || !r.After(time.Now()) {
+		t.Errorf("got %v\nwant between now and %v", r, expires)
+	}
+}
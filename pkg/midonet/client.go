@@ -17,6 +17,7 @@ package midonet
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -65,34 +66,62 @@ var (
 		},
 		[]string{"method", "resource"},
 	)
+
+	failureCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "failures_total",
+			Help:      "Number of MidoNet API calls failed or answered with an error status, by the reason",
+		},
+		[]string{"method", "resource", "reason"},
+	)
+
+	skippedWriteCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "skipped_writes_total",
+			Help:      "Number of writes skipped because the resource on MidoNet API was up to date",
+		},
+		[]string{"resource"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(apiLatency)
 	prometheus.MustRegister(callCount)
 	prometheus.MustRegister(errorCount)
+	prometheus.MustRegister(failureCount)
+	prometheus.MustRegister(skippedWriteCount)
 }
 
 // Client is a MidoNet API client.
+// A Client is safe for concurrent use by multiple goroutines.
 type Client struct {
 	config *Config
-	token  string
+	auth   Authenticator
 }
 
 // NewClient creates a Client.
 func NewClient(config *Config) *Client {
-	return &Client{
+	c := &Client{
 		config: config,
 	}
+	c.auth = newAuthenticator(config, c.executeRequest)
+	return c
 }
 
 func getZeroValue(res APIResource) APIResource {
 	return reflect.New(reflect.TypeOf(res).Elem()).Interface().(APIResource)
 }
 
-func (c *Client) exists(origRes APIResource) (bool, error) {
+func (c *Client) exists(ctx context.Context, origRes APIResource) (bool, error) {
 	res := getZeroValue(origRes)
-	resp, err := c.get(origRes, res)
+	resp, err := c.get(ctx, origRes, res)
+	if IsNotFound(err) {
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
@@ -100,14 +129,8 @@ func (c *Client) exists(origRes APIResource) (bool, error) {
 		"resp": resp,
 		"body": res,
 	}).Debug("Get result")
-	if resp.StatusCode/100 == 2 {
-		// REVISIT: we can check the contents
-		return true, nil
-	}
-	if resp.StatusCode == 404 {
-		return false, nil
-	}
-	return false, fmt.Errorf("Unexpected status %d", resp.StatusCode)
+	// REVISIT: we can check the contents
+	return true, nil
 }
 
 // Check if the resource needs a workaround for
@@ -123,12 +146,23 @@ func mna1315(res APIResource) bool {
 }
 
 // Push creates or updates the given resources on MidoNet API.
-func (c *Client) Push(resources []APIResource) error {
+// It gives up when the context is done.
+func (c *Client) Push(ctx context.Context, resources []APIResource) error {
 	for _, res := range resources {
-		// REVISIT: maybe we should save updates (and thus zk and
-		// midolman loads) by performing GET and compare first.
-		// Or we can make the MidoNet API detect and ignore no-op updates.
-		resp, body, err := c.post(res)
+		if c.config.skipNoopUpdates {
+			upToDate, err := c.upToDate(ctx, res)
+			if err != nil {
+				return err
+			}
+			if upToDate {
+				skippedWriteCount.With(prometheus.Labels{
+					"resource": res.MediaType(),
+				}).Inc()
+				continue
+			}
+		}
+		method := "POST"
+		resp, body, err := c.post(ctx, res)
 		if err != nil {
 			return err
 		}
@@ -145,11 +179,12 @@ func (c *Client) Push(resources []APIResource) error {
 			log.WithFields(log.Fields{
 				"resource": res,
 			}).Info("Referent doesn't exist yet?")
-			return fmt.Errorf("Referent doesn't exist yet?")
+			return newError("POST", res.Path("POST"), resp.StatusCode, body)
 		}
 		if resp.StatusCode == 409 || (resp.StatusCode == 500 && mna1315(res)) {
 			if res.Path("PUT") != "" {
-				resp, body, err = c.put(res)
+				method = "PUT"
+				resp, body, err = c.put(ctx, res)
 				if err != nil {
 					return err
 				}
@@ -162,13 +197,13 @@ func (c *Client) Push(resources []APIResource) error {
 				}
 			} else {
 				if res.Path("GET") != "" {
-					exists, err := c.exists(res)
+					exists, err := c.exists(ctx, res)
 					if err != nil {
 						return err
 					}
 					if !exists {
 						// assume a transient error
-						return fmt.Errorf("Unexpected 409")
+						return newError("POST", res.Path("POST"), resp.StatusCode, body)
 					}
 				}
 				// assume 409 meant ok
@@ -178,19 +213,34 @@ func (c *Client) Push(resources []APIResource) error {
 			}
 		}
 		if resp.StatusCode/100 != 2 {
-			log.WithFields(log.Fields{
-				"statusCode": resp.StatusCode,
-				"body":       body,
-			}).Fatal("Unexpected status code")
+			return newError(method, res.Path(method), resp.StatusCode, body)
 		}
 	}
 	return nil
 }
 
+// upToDate returns true if the resource on MidoNet API is same as
+// the given one.  It saves the writes (and thus ZooKeeper and midolman
+// loads) of resources which haven't changed, at the cost of a GET.
+// E.g. on a restart of the controllers, which pushes everything.
+// Note: The comparison ignores the fields MidoNet API fills with its
+// defaults.  See CheckDrift.
+func (c *Client) upToDate(ctx context.Context, res APIResource) (bool, error) {
+	if !checkable(res) {
+		return false, nil
+	}
+	drift, err := c.CheckDrift(ctx, res)
+	if err != nil {
+		return false, err
+	}
+	return drift == nil, nil
+}
+
 // Delete deletes the given resources on MidoNet API.
-func (c *Client) Delete(resources []APIResource) error {
+// It gives up when the context is done.
+func (c *Client) Delete(ctx context.Context, resources []APIResource) error {
 	for _, res := range resources {
-		resp, body, err := c.doRequest("DELETE", res.Path("DELETE"), nil, "")
+		resp, body, err := c.doRequest(ctx, "DELETE", res.Path("DELETE"), nil, "")
 		if err != nil {
 			return err
 		}
@@ -198,28 +248,28 @@ func (c *Client) Delete(resources []APIResource) error {
 		// MidoNet topology modifications, it happens e.g. when a removal
 		// of a Chain cascade-deleted Rules.
 		if resp.StatusCode/100 != 2 && resp.StatusCode != 404 {
-			log.WithFields(log.Fields{
-				"statusCode": resp.StatusCode,
-				"body":       body,
-			}).Fatal("Unexpected status code")
+			return newError("DELETE", res.Path("DELETE"), resp.StatusCode, body)
 		}
 	}
 	return nil
 }
 
-func (c *Client) post(res APIResource) (*http.Response, string, error) {
-	return c.doRequest("POST", res.Path("POST"), res, "")
+func (c *Client) post(ctx context.Context, res APIResource) (*http.Response, string, error) {
+	return c.doRequest(ctx, "POST", res.Path("POST"), res, "")
 }
 
-func (c *Client) put(res APIResource) (*http.Response, string, error) {
-	return c.doRequest("PUT", res.Path("PUT"), res, "")
+func (c *Client) put(ctx context.Context, res APIResource) (*http.Response, string, error) {
+	return c.doRequest(ctx, "PUT", res.Path("PUT"), res, "")
 }
 
-func (c *Client) get(id, result APIResource) (*http.Response, error) {
-	resp, body, err := c.doRequest("GET", id.Path("GET"), nil, id.MediaType())
+func (c *Client) get(ctx context.Context, id, result APIResource) (*http.Response, error) {
+	resp, body, err := c.doRequest(ctx, "GET", id.Path("GET"), nil, id.MediaType())
 	if err != nil {
 		return resp, err
 	}
+	if resp.StatusCode/100 != 2 {
+		return resp, newError("GET", id.Path("GET"), resp.StatusCode, body)
+	}
 	dec := json.NewDecoder(strings.NewReader(body))
 	err = dec.Decode(result)
 	return resp, err
@@ -228,49 +278,60 @@ func (c *Client) get(id, result APIResource) (*http.Response, error) {
 // List gets the list of the given resources on MidoNet API.
 // Note: the argument rs should be a pointer to an empty slice of
 // the struct.  E.g. a pointer to []Host
-func (c *Client) List(rs interface{}) (*http.Response, error) {
+func (c *Client) List(ctx context.Context, rs interface{}) (*http.Response, error) {
 	// assumption: rs is a pointer to an array of ListableResource
 	// E.g. *[]Host
 	t := reflect.TypeOf(rs)
 	et := t.Elem().Elem()
 	p := reflect.New(et)
 	r := p.Interface().(ListableResource)
-	resp, body, err := c.doRequest("GET", r.Path("LIST"), nil, r.CollectionMediaType())
+	return c.list(ctx, r.Path("LIST"), r.CollectionMediaType(), rs)
+}
+
+func (c *Client) list(ctx context.Context, path string, mediaType string, rs interface{}) (*http.Response, error) {
+	resp, body, err := c.doRequest(ctx, "GET", path, nil, mediaType)
 	if err != nil {
 		return resp, err
 	}
+	if resp.StatusCode/100 != 2 {
+		return resp, newError("GET", path, resp.StatusCode, body)
+	}
 	dec := json.NewDecoder(strings.NewReader(body))
 	err = dec.Decode(rs)
 	return resp, err
 }
 
-func (c *Client) doRequest(method string, path string, res APIResource, respType string) (*http.Response, string, error) {
-	resp, body, err := c.request(method, path, res, respType)
+func (c *Client) doRequest(ctx context.Context, method string, path string, res APIResource, respType string) (*http.Response, string, error) {
+	token, err := c.auth.Token(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, body, err := c.request(ctx, token, method, path, res, respType)
 	if err != nil {
 		return resp, body, err
 	}
 	if resp.StatusCode == 401 {
-		err = c.login()
+		token, err = c.auth.Refresh(ctx, token)
 		if err != nil {
 			return nil, "", err
 		}
-		resp, body, err = c.request(method, path, res, respType)
+		resp, body, err = c.request(ctx, token, method, path, res, respType)
 	}
 	return resp, body, err
 }
 
-func (c *Client) request(method string, path string, res APIResource, respType string) (*http.Response, string, error) {
-	req, err := c.prepareRequest(method, path, res, respType)
+func (c *Client) request(ctx context.Context, token string, method string, path string, res APIResource, respType string) (*http.Response, string, error) {
+	req, err := c.prepareRequest(ctx, method, path, res, respType)
 	if err != nil {
 		return nil, "", err
 	}
-	if c.token != "" {
-		req.Header.Add("X-Auth-Token", c.token)
+	if token != "" {
+		req.Header.Add("X-Auth-Token", token)
 	}
 	return c.executeRequest(req)
 }
 
-func (c *Client) prepareRequest(method string, path string, res APIResource, respType string) (*http.Request, error) {
+func (c *Client) prepareRequest(ctx context.Context, method string, path string, res APIResource, respType string) (*http.Request, error) {
 	url := c.config.api + path
 	clog := log.WithFields(log.Fields{
 		"method": method,
@@ -289,6 +350,7 @@ func (c *Client) prepareRequest(method string, path string, res APIResource, res
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 	if res != nil {
 		req.Header.Add("Content-Type", res.MediaType())
 	}
@@ -302,17 +364,15 @@ func (c *Client) prepareRequest(method string, path string, res APIResource, res
 func (c *Client) executeRequest(req *http.Request) (*http.Response, string, error) {
 	resType := req.Header.Get("Content-Type")
 	startTime := time.Now()
-	client := http.DefaultClient
-	resp, err := client.Do(req)
+	resp, err := c.config.httpClient.Do(req)
 	if err != nil {
-		errorCount.With(prometheus.Labels{
-			"method":   strings.ToLower(req.Method),
-			"resource": resType,
-		}).Inc()
-		return nil, "", err
+		return nil, "", requestError(req, resType, err)
 	}
 	defer resp.Body.Close()
-	respBody, _ := ioutil.ReadAll(resp.Body)
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", requestError(req, resType, err)
+	}
 	timeTaken := time.Since(startTime).Seconds()
 	// REVISIT: resource type for DELETE
 	if req.Method == "GET" {
@@ -325,6 +385,13 @@ func (c *Client) executeRequest(req *http.Request) (*http.Response, string, erro
 	}
 	apiLatency.With(metricsLabels).Observe(timeTaken)
 	callCount.With(metricsLabels).Inc()
+	if resp.StatusCode/100 != 2 {
+		failureCount.With(prometheus.Labels{
+			"method":   strings.ToLower(req.Method),
+			"resource": resType,
+			"reason":   string(reasonForStatusCode(resp.StatusCode)),
+		}).Inc()
+	}
 	clog := log.WithFields(log.Fields{
 		"statusCode":   resp.StatusCode,
 		"responseBody": string(respBody),
@@ -333,37 +400,37 @@ func (c *Client) executeRequest(req *http.Request) (*http.Response, string, erro
 	return resp, string(respBody), nil
 }
 
-//  https://docs.midonet.org/docs/latest-en/rest-api/content/authentication-authorization.html
-
-type tokenInfo struct {
-	Key     string `json:"key"`
-	Expires string `json:"expires"`
+// requestError records a request which failed without a response.
+// It returns the cause rather than the wrapped error if the context is
+// done, so that the callers can tell a cancellation from a network error.
+func requestError(req *http.Request, resType string, err error) error {
+	if ctxErr := req.Context().Err(); ctxErr != nil {
+		err = ctxErr
+	}
+	errorCount.With(prometheus.Labels{
+		"method":   strings.ToLower(req.Method),
+		"resource": resType,
+	}).Inc()
+	failureCount.With(prometheus.Labels{
+		"method":   strings.ToLower(req.Method),
+		"resource": resType,
+		"reason":   string(Reason(err)),
+	}).Inc()
+	return err
 }
 
-func (c *Client) login() error {
-	user := c.config.username
-	pass := c.config.password
-	project := c.config.project
-	req, err := c.prepareRequest("POST", "/login", nil, "")
-	if err != nil {
-		return err
-	}
-	req.SetBasicAuth(user, pass)
-	req.Header.Add("X-Auth-Project", project)
-	resp, body, err := c.executeRequest(req)
+const systemStateMediaType = "application/vnd.org.midonet.SystemState-v2+json"
+
+// Ping checks if MidoNet API is reachable and we can log in to it.
+// Note: It GETs /system_state rather than the root because the latter
+// doesn't need a token and would succeed with a bad credential.
+func (c *Client) Ping(ctx context.Context) error {
+	resp, body, err := c.doRequest(ctx, "GET", "/system_state", nil, systemStateMediaType)
 	if err != nil {
 		return err
 	}
 	if resp.StatusCode/100 != 2 {
-		log.WithField("statusCode", resp.StatusCode).Fatal("Login failure")
-	}
-	dec := json.NewDecoder(strings.NewReader(body))
-	info := &tokenInfo{}
-	err = dec.Decode(info)
-	if err != nil {
-		return err
+		return newError("GET", "/system_state", resp.StatusCode, body)
 	}
-	log.WithField("tokenInfo", info).Info("login succeeded.")
-	c.token = info.Key
-	return err
+	return nil
 }
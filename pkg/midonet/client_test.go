@@ -16,8 +16,14 @@
 package midonet
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/midonet/midonet-kubernetes/pkg/config"
 )
 
 func TestGetZeroValue(t *testing.T) {
@@ -30,3 +36,54 @@ func TestGetZeroValue(t *testing.T) {
 		t.Errorf("got %v\nwant %v", actual, expected)
 	}
 }
+
+// newHangingServer returns a server which doesn't respond until
+// the request is canceled or the server is closed.
+func newHangingServer() (*httptest.Server, chan struct{}) {
+	done := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	return s, done
+}
+
+func TestCancel(t *testing.T) {
+	s, done := newHangingServer()
+	defer s.Close()
+	defer close(done)
+	c := NewClient(NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI: s.URL,
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	err := c.Push(ctx, []APIResource{&Bridge{}})
+	if !IsCanceled(err) {
+		t.Errorf("got %v\nwant Canceled", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = c.Delete(ctx, []APIResource{&Bridge{}})
+	if !IsTimeout(err) {
+		t.Errorf("got %v\nwant Timeout", err)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	s, done := newHangingServer()
+	defer s.Close()
+	defer close(done)
+	c := NewClient(NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI:            s.URL,
+		MidoNetRequestTimeout: 100 * time.Millisecond,
+	}))
+	err := c.Ping(context.Background())
+	if !IsTimeout(err) {
+		t.Errorf("got %v\nwant Timeout", err)
+	}
+}
@@ -16,6 +16,17 @@
 package midonet
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
 	"github.com/midonet/midonet-kubernetes/pkg/config"
 )
 
@@ -25,14 +36,100 @@ type Config struct {
 	username string
 	password string
 	project  string
+
+	// auth is one of "midonet", "keystone" and "token".
+	auth     string
+	token    string
+	keystone keystoneConfig
+
+	// skipNoopUpdates makes Client.Push compare resources with
+	// the ones on MidoNet API before writing them.
+	skipNoopUpdates bool
+
+	// httpClient is shared by Clients created with this Config,
+	// so that they share the connection pool.
+	httpClient *http.Client
+}
+
+type keystoneConfig struct {
+	url                 string
+	userDomain          string
+	projectDomain       string
+	appCredentialID     string
+	appCredentialSecret string
 }
 
 // NewConfigFromEnvConfig creates Config from envconfig instance.
 func NewConfigFromEnvConfig(config *config.Config) *Config {
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid MidoNet API TLS configuration")
+	}
 	return &Config{
 		api:      config.MidoNetAPI,
 		username: config.MidoNetUserName,
 		password: config.MidoNetPassword,
 		project:  config.MidoNetProject,
+		auth:     config.MidoNetAuth,
+		token:    config.MidoNetToken,
+		keystone: keystoneConfig{
+			url:                 strings.TrimRight(config.KeystoneURL, "/"),
+			userDomain:          config.KeystoneUserDomain,
+			projectDomain:       config.KeystoneProjectDomain,
+			appCredentialID:     config.KeystoneApplicationCredentialID,
+			appCredentialSecret: config.KeystoneApplicationCredentialSecret,
+		},
+		skipNoopUpdates: config.MidoNetSkipNoopUpdates,
+		httpClient:      httpClient,
+	}
+}
+
+func newTLSConfig(config *config.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         config.MidoNetServerName,
+		InsecureSkipVerify: config.MidoNetInsecureSkipVerify,
+	}
+	if config.MidoNetCAFile != "" {
+		pem, err := ioutil.ReadFile(config.MidoNetCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in %s", config.MidoNetCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.MidoNetCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.MidoNetCertFile, config.MidoNetKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func newHTTPClient(config *config.Config) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	// Note: Similar to http.DefaultTransport except the timeouts and TLS.
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   config.MidoNetConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: config.MidoNetConnectTimeout,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
 	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   config.MidoNetRequestTimeout,
+	}, nil
 }
@@ -0,0 +1,83 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package midonet
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/midonet/midonet-kubernetes/pkg/config"
+)
+
+func TestTLS(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+	f, err := ioutil.TempFile("", "ca")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	defer os.Remove(f.Name())
+	pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+	f.Close()
+
+	// The test server's certificate is for example.com
+	c := NewClient(NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI:            s.URL,
+		MidoNetCAFile:         f.Name(),
+		MidoNetServerName:     "example.com",
+		MidoNetRequestTimeout: 10 * time.Second,
+	}))
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+
+	c = NewClient(NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI: s.URL,
+	}))
+	if err := c.Ping(context.Background()); err == nil {
+		t.Errorf("got nil\nwant an error for the unknown CA")
+	}
+
+	c = NewClient(NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI:                s.URL,
+		MidoNetInsecureSkipVerify: true,
+	}))
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+}
+
+func TestTLSInvalidCA(t *testing.T) {
+	f, err := ioutil.TempFile("", "ca")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("not a certificate")
+	f.Close()
+	_, err = newHTTPClient(&config.Config{MidoNetCAFile: f.Name()})
+	if err == nil {
+		t.Errorf("got nil\nwant an error")
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package midonet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Drift describes how a resource on MidoNet API differs from
+// the desired one.
+type Drift struct {
+	// Missing is true if the resource doesn't exist.
+	Missing bool
+
+	// Fields are the JSON field names whose values differ.
+	Fields []string
+}
+
+// CheckDrift compares the given resource with the one on MidoNet API.
+// It returns nil if they are same, or if the resource can't be checked.
+// Only the fields which the given resource has are compared because
+// MidoNet API fills the rest with its defaults.
+func (c *Client) CheckDrift(ctx context.Context, res APIResource) (*Drift, error) {
+	if !checkable(res) {
+		return nil, nil
+	}
+	path := res.Path("GET")
+	resp, body, err := c.doRequest(ctx, "GET", path, nil, res.MediaType())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == 404 {
+		return &Drift{Missing: true}, nil
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, newError("GET", path, resp.StatusCode, body)
+	}
+	var actual map[string]interface{}
+	err = json.Unmarshal([]byte(body), &actual)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := diffFields(res, actual)
+	if err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return nil, nil
+	}
+	return &Drift{Fields: fields}, nil
+}
+
+// diffFields returns the sorted JSON field names whose values differ
+// between the given resource and the decoded JSON object from
+// MidoNet API.  Only the fields which the resource has are compared.
+func diffFields(res APIResource, actual map[string]interface{}) ([]string, error) {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	var desired map[string]interface{}
+	err = json.Unmarshal(data, &desired)
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	for k, v := range desired {
+		if v == nil {
+			continue
+		}
+		if !reflect.DeepEqual(v, actual[k]) {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// checkable returns true if the resource can be compared with the one
+// on MidoNet API.
+func checkable(res APIResource) bool {
+	if res.Path("GET") == "" {
+		// E.g. PortLink
+		return false
+	}
+	if _, ok := res.(*TunnelZone); ok {
+		// A TunnelZone with the same name and type might exist with
+		// a different ID.  See the workaround for MNA-1293 in Push.
+		return false
+	}
+	return true
+}
+
+// Repair makes the resource on MidoNet API same as the given one.
+// A resource which doesn't support PUT is deleted and re-created.
+// Note: A re-created Rule is inserted at the head of the Chain,
+// which can change the order of evaluation.  Use RepairRule for Rules.
+// Note: A deletion of a Chain cascade-deletes its Rules.  They will be
+// found missing and re-created by a later check.
+func (c *Client) Repair(ctx context.Context, res APIResource, drift *Drift) error {
+	if drift.Missing {
+		return c.Push(ctx, []APIResource{res})
+	}
+	if path := res.Path("PUT"); path != "" {
+		resp, body, err := c.put(ctx, res)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode/100 != 2 {
+			return newError("PUT", path, resp.StatusCode, body)
+		}
+		return nil
+	}
+	err := c.Delete(ctx, []APIResource{res})
+	if err != nil {
+		return err
+	}
+	return c.Push(ctx, []APIResource{res})
+}
+
+// RepairRule re-creates the given Rule at its original position in
+// the Chain, so that the order of evaluation is kept.  rules are the
+// Rules of the Translation in the same Chain, in the order they were
+// pushed.  They are used to find the position of a missing Rule.
+// Note: The other Rules in the Chain are left intact.  While the Rule
+// is being re-created, the traffic is evaluated without it.
+func (c *Client) RepairRule(ctx context.Context, rule *Rule, rules []*Rule, drift *Drift) error {
+	var actual []*Rule
+	_, err := c.list(ctx, fmt.Sprintf("/chains/%s/rules", rule.Parent.ID), ruleCollectionMediaType, &actual)
+	if err != nil {
+		return err
+	}
+	position := rulePosition(rule, rules, actual)
+	if !drift.Missing {
+		err = c.Delete(ctx, []APIResource{rule})
+		if err != nil {
+			return err
+		}
+	}
+	r := *rule
+	r.Position = position
+	return c.Push(ctx, []APIResource{&r})
+}
+
+// rulePosition returns the position to re-create the Rule at, given
+// the Rules of the Translation in the pushed order and the Rules in
+// the Chain in the order of evaluation.  It returns 0 if unknown.
+func rulePosition(rule *Rule, rules []*Rule, actual []*Rule) int {
+	positions := make(map[uuid.UUID]int)
+	for i, r := range actual {
+		positions[*r.ID] = i + 1
+	}
+	if p, ok := positions[*rule.ID]; ok {
+		return p
+	}
+	// The Rule is missing.  Put it next to the nearest Rule which
+	// exists.
+	i := 0
+	for i < len(rules) && *rules[i].ID != *rule.ID {
+		i++
+	}
+	// Note: MidoNet API inserts a Rule at the head of the Chain.
+	// The Rules pushed earlier are evaluated later.
+	for j := i - 1; j >= 0; j-- {
+		if p, ok := positions[*rules[j].ID]; ok {
+			return p
+		}
+	}
+	for j := i + 1; j < len(rules); j++ {
+		if p, ok := positions[*rules[j].ID]; ok {
+			return p + 1
+		}
+	}
+	return 0
+}
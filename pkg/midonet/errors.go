@@ -0,0 +1,182 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package midonet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrorReason classifies an Error.
+type ErrorReason string
+
+const (
+	// ReasonNotFound is 404.  For a POST, it usually means that
+	// a referent (e.g. the parent) doesn't exist yet.
+	ReasonNotFound ErrorReason = "NotFound"
+
+	// ReasonConflict is 409 which we couldn't resolve.
+	ReasonConflict ErrorReason = "Conflict"
+
+	// ReasonUnauthorized is 401 and 403.  E.g. a login failure.
+	ReasonUnauthorized ErrorReason = "Unauthorized"
+
+	// ReasonBadRequest is 400 and other 4xx.
+	ReasonBadRequest ErrorReason = "BadRequest"
+
+	// ReasonServerError is 5xx.
+	ReasonServerError ErrorReason = "ServerError"
+
+	// ReasonCanceled is used by Reason for a request given up because
+	// its context was canceled.  E.g. on shutdown.
+	ReasonCanceled ErrorReason = "Canceled"
+
+	// ReasonTimeout is used by Reason for a request which didn't
+	// complete in time.  Either the deadline of its context or
+	// the request timeout.
+	ReasonTimeout ErrorReason = "Timeout"
+
+	// ReasonUnknown is used by Reason for errors other than Error.
+	// E.g. network errors.
+	ReasonUnknown ErrorReason = "Unknown"
+)
+
+// ErrorBody is the error entity returned by MidoNet API.
+type ErrorBody struct {
+	Message    string      `json:"message"`
+	Code       int         `json:"code"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// Violation is a validation error in ErrorBody.
+type Violation struct {
+	Property string `json:"property"`
+	Message  string `json:"message"`
+}
+
+// Error is an unexpected response from MidoNet API.
+type Error struct {
+	Reason     ErrorReason
+	Method     string
+	Path       string
+	StatusCode int
+
+	// Body is the parsed response body.  nil if the response didn't
+	// have a MidoNet error entity.
+	Body *ErrorBody
+
+	// RawBody is the response body as it is.
+	RawBody string
+}
+
+func (e *Error) Error() string {
+	msg := strings.TrimSpace(e.RawBody)
+	if e.Body != nil && e.Body.Message != "" {
+		msg = e.Body.Message
+		for _, v := range e.Body.Violations {
+			msg += fmt.Sprintf("; %s: %s", v.Property, v.Message)
+		}
+	}
+	return fmt.Sprintf("MidoNet API %s %s: %s (%d): %s", e.Method, e.Path, e.Reason, e.StatusCode, msg)
+}
+
+func reasonForStatusCode(code int) ErrorReason {
+	switch {
+	case code == 404:
+		return ReasonNotFound
+	case code == 409:
+		return ReasonConflict
+	case code == 401 || code == 403:
+		return ReasonUnauthorized
+	case code/100 == 4:
+		return ReasonBadRequest
+	case code/100 == 5:
+		return ReasonServerError
+	}
+	// Note: 1xx and 3xx are not expected from MidoNet API.
+	return ReasonServerError
+}
+
+func newError(method, path string, statusCode int, body string) *Error {
+	e := &Error{
+		Reason:     reasonForStatusCode(statusCode),
+		Method:     method,
+		Path:       path,
+		StatusCode: statusCode,
+		RawBody:    body,
+	}
+	var eb ErrorBody
+	if json.Unmarshal([]byte(body), &eb) == nil && eb.Message != "" {
+		e.Body = &eb
+	}
+	return e
+}
+
+// Reason returns the ErrorReason of the given error.
+func Reason(err error) ErrorReason {
+	if e, ok := err.(*Error); ok {
+		return e.Reason
+	}
+	if err == context.Canceled {
+		return ReasonCanceled
+	}
+	if err == context.DeadlineExceeded {
+		return ReasonTimeout
+	}
+	// Note: *url.Error from http.Client is a net.Error.
+	if e, ok := err.(net.Error); ok && e.Timeout() {
+		return ReasonTimeout
+	}
+	return ReasonUnknown
+}
+
+// IsNotFound returns true if the error is ReasonNotFound.
+func IsNotFound(err error) bool {
+	return Reason(err) == ReasonNotFound
+}
+
+// IsConflict returns true if the error is ReasonConflict.
+func IsConflict(err error) bool {
+	return Reason(err) == ReasonConflict
+}
+
+// IsUnauthorized returns true if the error is ReasonUnauthorized.
+func IsUnauthorized(err error) bool {
+	return Reason(err) == ReasonUnauthorized
+}
+
+// IsBadRequest returns true if the error is ReasonBadRequest.
+func IsBadRequest(err error) bool {
+	return Reason(err) == ReasonBadRequest
+}
+
+// IsCanceled returns true if the error is ReasonCanceled.
+func IsCanceled(err error) bool {
+	return Reason(err) == ReasonCanceled
+}
+
+// IsTimeout returns true if the error is ReasonTimeout.
+func IsTimeout(err error) bool {
+	return Reason(err) == ReasonTimeout
+}
+
+// IsServerError returns true if the error is ReasonServerError.
+func IsServerError(err error) bool {
+	return Reason(err) == ReasonServerError
+}
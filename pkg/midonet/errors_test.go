@@ -0,0 +1,64 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package midonet
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestNewError(t *testing.T) {
+	cases := []struct {
+		code     int
+		body     string
+		reason   ErrorReason
+		expected string
+	}{
+		{404, `{"message":"Bridge not found","code":404}`, ReasonNotFound,
+			"MidoNet API POST /bridges/x/ports: NotFound (404): Bridge not found"},
+		{409, "", ReasonConflict,
+			"MidoNet API POST /bridges/x/ports: Conflict (409): "},
+		{401, "Unauthorized\n", ReasonUnauthorized,
+			"MidoNet API POST /bridges/x/ports: Unauthorized (401): Unauthorized"},
+		{400, `{"message":"Validation error(s) found","code":400,"violations":[{"property":"name","message":"may not be null"}]}`, ReasonBadRequest,
+			"MidoNet API POST /bridges/x/ports: BadRequest (400): Validation error(s) found; name: may not be null"},
+		{503, "<html></html>", ReasonServerError,
+			"MidoNet API POST /bridges/x/ports: ServerError (503): <html></html>"},
+	}
+	for _, tc := range cases {
+		err := newError("POST", "/bridges/x/ports", tc.code, tc.body)
+		if Reason(err) != tc.reason {
+			t.Errorf("%d: got reason %s\nwant %s", tc.code, Reason(err), tc.reason)
+		}
+		if err.Error() != tc.expected {
+			t.Errorf("%d: got %q\nwant %q", tc.code, err.Error(), tc.expected)
+		}
+	}
+}
+
+func TestReason(t *testing.T) {
+	if Reason(fmt.Errorf("connection refused")) != ReasonUnknown {
+		t.Errorf("expected ReasonUnknown")
+	}
+	err := newError("DELETE", "/chains/x", 404, "")
+	if !IsNotFound(err) || IsConflict(err) || IsServerError(err) {
+		t.Errorf("unexpected classification of %v", err)
+	}
+	if !IsCanceled(context.Canceled) || !IsTimeout(context.DeadlineExceeded) {
+		t.Errorf("unexpected classification of context errors")
+	}
+}
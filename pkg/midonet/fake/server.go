@@ -0,0 +1,587 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// object is a resource stored in the Server.
+type object struct {
+	path       string // e.g. /ports/<id>
+	collection string // the path the object was POSTed to
+	parent     string // the path of the parent object, or ""
+	body       map[string]interface{}
+	seq        int
+}
+
+// collection describes a POST-able collection.
+type collection struct {
+	pattern *regexp.Regexp
+	// parent is the format of the path of the parent object.
+	// The argument is the parent ID in the collection path.
+	parent string
+	// item returns the path of the object to create.
+	item func(parentID string, body map[string]interface{}) (string, error)
+}
+
+func idItem(format string) func(string, map[string]interface{}) (string, error) {
+	return func(_ string, body map[string]interface{}) (string, error) {
+		id, ok := body["id"].(string)
+		if !ok || id == "" {
+			id = uuid.New().String()
+			body["id"] = id
+		}
+		return fmt.Sprintf(format, id), nil
+	}
+}
+
+func stringField(body map[string]interface{}, name string) (string, error) {
+	v, ok := body[name].(string)
+	if !ok || v == "" {
+		return "", fmt.Errorf("%s is required", name)
+	}
+	return v, nil
+}
+
+// See Path methods in pkg/midonet/resources.go
+var collections = []collection{
+	{regexp.MustCompile(`^/tunnel_zones$`), "", idItem("/tunnel_zones/%s")},
+	{regexp.MustCompile(`^/tunnel_zones/([^/]+)/hosts$`), "/tunnel_zones/%s",
+		func(parentID string, body map[string]interface{}) (string, error) {
+			hostID, err := stringField(body, "hostId")
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("/tunnel_zones/%s/hosts/%s", parentID, hostID), nil
+		}},
+	{regexp.MustCompile(`^/routers$`), "", idItem("/routers/%s")},
+	{regexp.MustCompile(`^/bridges$`), "", idItem("/bridges/%s")},
+	{regexp.MustCompile(`^/chains$`), "", idItem("/chains/%s")},
+	{regexp.MustCompile(`^/bridges/([^/]+)/ports$`), "/bridges/%s", idItem("/ports/%s")},
+	{regexp.MustCompile(`^/routers/([^/]+)/ports$`), "/routers/%s", idItem("/ports/%s")},
+	{regexp.MustCompile(`^/ports/([^/]+)/link$`), "/ports/%s",
+		func(parentID string, _ map[string]interface{}) (string, error) {
+			return fmt.Sprintf("/ports/%s/link", parentID), nil
+		}},
+	{regexp.MustCompile(`^/routers/([^/]+)/routes$`), "/routers/%s", idItem("/routes/%s")},
+	{regexp.MustCompile(`^/routers/([^/]+)/bgp_peers$`), "/routers/%s", idItem("/bgp_peers/%s")},
+	{regexp.MustCompile(`^/routers/([^/]+)/bgp_networks$`), "/routers/%s", idItem("/bgp_networks/%s")},
+	{regexp.MustCompile(`^/chains/([^/]+)/rules$`), "/chains/%s", idItem("/rules/%s")},
+	{regexp.MustCompile(`^/hosts/([^/]+)/ports$`), "/hosts/%s",
+		func(parentID string, body map[string]interface{}) (string, error) {
+			portID, err := stringField(body, "portId")
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("/hosts/%s/ports/%s", parentID, portID), nil
+		}},
+	{regexp.MustCompile(`^/bridges/([^/]+)/mac_table$`), "/bridges/%s",
+		func(parentID string, body map[string]interface{}) (string, error) {
+			mac, err := stringField(body, "macAddr")
+			if err != nil {
+				return "", err
+			}
+			portID, err := stringField(body, "portId")
+			if err != nil {
+				return "", err
+			}
+			mac = strings.Replace(mac, ":", "-", -1)
+			return fmt.Sprintf("/bridges/%s/mac_table/%s_%s", parentID, mac, portID), nil
+		}},
+	{regexp.MustCompile(`^/bridges/([^/]+)/arp_table$`), "/bridges/%s",
+		func(parentID string, body map[string]interface{}) (string, error) {
+			ip, err := stringField(body, "ip")
+			if err != nil {
+				return "", err
+			}
+			mac, err := stringField(body, "mac")
+			if err != nil {
+				return "", err
+			}
+			mac = strings.Replace(mac, ":", "-", -1)
+			return fmt.Sprintf("/bridges/%s/arp_table/%s_%s", parentID, ip, mac), nil
+		}},
+}
+
+// references maps JSON fields referring to other objects to the format
+// of the path of the referent.
+var references = map[string]string{
+	"inboundFilterId":  "/chains/%s",
+	"outboundFilterId": "/chains/%s",
+	"jumpChainId":      "/chains/%s",
+	"nextHopPort":      "/ports/%s",
+	"portId":           "/ports/%s",
+	"peerId":           "/ports/%s",
+	"hostId":           "/hosts/%s",
+}
+
+type injectedError struct {
+	method string
+	path   string
+	code   int
+}
+
+// Server is a fake MidoNet API server.
+type Server struct {
+	// URL is the base URL of the API.  It can be used as
+	// MIDONETKUBE_MIDONET_API.
+	URL string
+
+	server *httptest.Server
+
+	mu       sync.Mutex
+	objects  map[string]*object
+	seq      int
+	requests []string
+	errors   []injectedError
+	username string
+	password string
+	tokens   map[string]bool
+}
+
+// NewServer starts a Server.  The caller should Close it.
+func NewServer() *Server {
+	s := &Server{
+		objects: make(map[string]*object),
+		tokens:  make(map[string]bool),
+	}
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts down the Server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// RequireAuth makes the Server require an X-Auth-Token obtained with
+// the given credentials via /login.
+func (s *Server) RequireAuth(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.username = username
+	s.password = password
+}
+
+// ExpireTokens invalidates the tokens issued so far.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]bool)
+}
+
+// FailNext makes the next request with the given method and path fail
+// with the given status code.
+func (s *Server) FailNext(method, path string, code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = append(s.errors, injectedError{method, path, code})
+}
+
+// AddHost adds a Host.  Hosts can't be created via the API.
+func (s *Server) AddHost(id uuid.UUID, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(&object{
+		path:       fmt.Sprintf("/hosts/%s", id),
+		collection: "/hosts",
+		body: map[string]interface{}{
+			"id":   id.String(),
+			"name": name,
+		},
+	})
+}
+
+// Get returns the object at the given path.  E.g. "/bridges/<id>"
+// It returns nil if it doesn't exist.
+func (s *Server) Get(path string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[path]
+	if !ok {
+		return nil
+	}
+	return copyBody(obj.body)
+}
+
+// Modify overwrites fields of the object at the given path, as if
+// someone edited it by hand.
+func (s *Server) Modify(path string, fields map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[path]
+	if !ok {
+		return
+	}
+	for k, v := range fields {
+		obj.body[k] = v
+	}
+}
+
+// Remove deletes the object at the given path and its children,
+// as if someone deleted it by hand.
+func (s *Server) Remove(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delete(path)
+}
+
+// Exists returns true if the object at the given path exists.
+func (s *Server) Exists(path string) bool {
+	return s.Get(path) != nil
+}
+
+// Paths returns the sorted paths of all objects.
+func (s *Server) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var paths []string
+	for p := range s.objects {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Rules returns the rules in the given Chain in the order of evaluation.
+// Note: MidoNet API inserts a rule at the head of the chain by default.
+func (s *Server) Rules(chainID string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parent := fmt.Sprintf("/chains/%s", chainID)
+	var objs []*object
+	for _, obj := range s.objects {
+		if obj.parent == parent {
+			objs = append(objs, obj)
+		}
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].seq > objs[j].seq
+	})
+	var rules []map[string]interface{}
+	for _, obj := range objs {
+		rules = append(rules, copyBody(obj.body))
+	}
+	return rules
+}
+
+// Requests returns the requests the Server has received so far,
+// as "METHOD path" strings.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// ResetRequests clears the requests recorded so far.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+func copyBody(body map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		c[k] = v
+	}
+	return c
+}
+
+func (s *Server) store(obj *object) {
+	s.seq++
+	obj.seq = s.seq
+	s.objects[obj.path] = obj
+}
+
+// moveRule moves the rule to the given position, starting with 1,
+// in the order of evaluation.
+func (s *Server) moveRule(rule *object, position int) {
+	var objs []*object
+	var seqs []int
+	for _, obj := range s.objects {
+		if obj.collection == rule.collection {
+			if obj != rule {
+				objs = append(objs, obj)
+			}
+			seqs = append(seqs, obj.seq)
+		}
+	}
+	// Note: Rules with larger seq come first.  See Rules.
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].seq > objs[j].seq
+	})
+	sort.Sort(sort.Reverse(sort.IntSlice(seqs)))
+	i := position - 1
+	if i < 0 || i > len(objs) {
+		i = 0
+	}
+	objs = append(objs[:i], append([]*object{rule}, objs[i:]...)...)
+	for i, obj := range objs {
+		obj.seq = seqs[i]
+	}
+}
+
+// delete deletes the object and its children recursively.
+func (s *Server) delete(path string) {
+	delete(s.objects, path)
+	for p, obj := range s.objects {
+		if obj.parent == path {
+			s.delete(p)
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": fmt.Sprintf(format, args...),
+		"code":    code,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	method := r.Method
+	path := r.URL.Path
+	clog := log.WithFields(log.Fields{
+		"method": method,
+		"path":   path,
+	})
+	clog.Debug("Fake MidoNet API request")
+	s.requests = append(s.requests, fmt.Sprintf("%s %s", method, path))
+	for i, e := range s.errors {
+		if e.method == method && e.path == path {
+			s.errors = append(s.errors[:i], s.errors[i+1:]...)
+			writeError(w, e.code, "Injected error")
+			return
+		}
+	}
+	if path == "/login" {
+		s.login(w, r)
+		return
+	}
+	if method == "GET" && path == "/" {
+		// Like MidoNet API, the root doesn't need a token.
+		writeJSON(w, http.StatusOK, map[string]interface{}{"version": "5"})
+		return
+	}
+	if s.username != "" && !s.tokens[r.Header.Get("X-Auth-Token")] {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+	var body map[string]interface{}
+	if method == "POST" || method == "PUT" {
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Malformed request body: %v", err)
+			return
+		}
+	}
+	switch method {
+	case "POST":
+		s.post(w, path, body)
+	case "PUT":
+		s.put(w, path, body)
+	case "GET":
+		s.get(w, path, r.URL.Query().Get("tenant_id"))
+	case "DELETE":
+		s.del(w, path)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Unsupported method %s", method)
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	username, password, _ := r.BasicAuth()
+	if r.Method != "POST" || username != s.username || password != s.password {
+		writeError(w, http.StatusUnauthorized, "Login failed")
+		return
+	}
+	token := uuid.New().String()
+	s.tokens[token] = true
+	writeJSON(w, http.StatusOK, map[string]string{
+		"key":     token,
+		"expires": "",
+	})
+}
+
+// checkReferences returns the status code to reply if a referent
+// doesn't exist, or 0.
+func (s *Server) checkReferences(body map[string]interface{}) (int, string) {
+	for field, format := range references {
+		id, ok := body[field].(string)
+		if !ok || id == "" {
+			continue
+		}
+		p := fmt.Sprintf(format, id)
+		if _, ok := s.objects[p]; ok {
+			continue
+		}
+		if field == "nextHopPort" {
+			// MidoNet API returns 400 for this case.
+			// (ROUTE_NEXT_HOP_PORT_NOT_NULL)
+			return http.StatusBadRequest, p
+		}
+		return http.StatusNotFound, p
+	}
+	return 0, ""
+}
+
+func (s *Server) post(w http.ResponseWriter, path string, body map[string]interface{}) {
+	for _, c := range collections {
+		m := c.pattern.FindStringSubmatch(path)
+		if m == nil {
+			continue
+		}
+		var parentID, parent string
+		if c.parent != "" {
+			parentID = m[1]
+			parent = fmt.Sprintf(c.parent, parentID)
+			if _, ok := s.objects[parent]; !ok {
+				writeError(w, http.StatusNotFound, "%s not found", parent)
+				return
+			}
+		}
+		item, err := c.item(parentID, body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		if code, p := s.checkReferences(body); code != 0 {
+			writeError(w, code, "%s not found", p)
+			return
+		}
+		if _, ok := s.objects[item]; ok {
+			writeError(w, http.StatusConflict, "%s already exists", item)
+			return
+		}
+		obj := &object{
+			path:       item,
+			collection: path,
+			parent:     parent,
+			body:       body,
+		}
+		s.store(obj)
+		if position, ok := body["position"].(float64); ok {
+			delete(body, "position")
+			s.moveRule(obj, int(position))
+		}
+		w.Header().Set("Location", s.URL+item)
+		writeJSON(w, http.StatusCreated, body)
+		return
+	}
+	writeError(w, http.StatusNotFound, "Unknown collection %s", path)
+}
+
+func (s *Server) put(w http.ResponseWriter, path string, body map[string]interface{}) {
+	obj, ok := s.objects[path]
+	if !ok {
+		writeError(w, http.StatusNotFound, "%s not found", path)
+		return
+	}
+	if code, p := s.checkReferences(body); code != 0 {
+		writeError(w, code, "%s not found", p)
+		return
+	}
+	if id, ok := obj.body["id"]; ok {
+		body["id"] = id
+	}
+	obj.body = body
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) get(w http.ResponseWriter, path string, tenant string) {
+	if path == "/system_state" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"state":          "ACTIVE",
+			"availability":   "READWRITE",
+			"writeVersion":   "5",
+			"operationState": "ACTIVE",
+		})
+		return
+	}
+	if obj, ok := s.objects[path]; ok {
+		writeJSON(w, http.StatusOK, obj.body)
+		return
+	}
+	if !isCollection(path) {
+		writeError(w, http.StatusNotFound, "%s not found", path)
+		return
+	}
+	var objs []*object
+	for _, obj := range s.objects {
+		if obj.collection != path {
+			continue
+		}
+		if tenant != "" && obj.body["tenantId"] != tenant {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	// Rules are in the order of evaluation.  See Rules.
+	rules := strings.HasSuffix(path, "/rules")
+	sort.Slice(objs, func(i, j int) bool {
+		return (objs[i].seq < objs[j].seq) != rules
+	})
+	list := []map[string]interface{}{}
+	for _, obj := range objs {
+		list = append(list, obj.body)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func isCollection(path string) bool {
+	if path == "/hosts" {
+		return true
+	}
+	for _, c := range collections {
+		if c.pattern.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) del(w http.ResponseWriter, path string) {
+	if _, ok := s.objects[path]; !ok {
+		writeError(w, http.StatusNotFound, "%s not found", path)
+		return
+	}
+	s.delete(path)
+	w.WriteHeader(http.StatusNoContent)
+}
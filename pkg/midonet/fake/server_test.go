@@ -0,0 +1,326 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/midonet/midonet-kubernetes/pkg/config"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+func newClient(s *Server) *midonet.Client {
+	return midonet.NewClient(midonet.NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI:      s.URL,
+		MidoNetUserName: "admin",
+		MidoNetPassword: "secret",
+	}))
+}
+
+func TestPushAndDelete(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.RequireAuth("admin", "secret")
+	c := newClient(s)
+	bridgeID := uuid.New()
+	portID := uuid.New()
+	chainID := uuid.New()
+	port := &midonet.Port{
+		Parent:          midonet.Parent{ID: &bridgeID},
+		ID:              &portID,
+		Type:            "Bridge",
+		InboundFilterID: &chainID,
+	}
+	bridge := &midonet.Bridge{ID: &bridgeID, Name: "b"}
+	chain := &midonet.Chain{ID: &chainID, Name: "c"}
+
+	// The parent doesn't exist yet
+	err := c.Push(context.Background(), []midonet.APIResource{port})
+	if !midonet.IsNotFound(err) {
+		t.Errorf("got %v\nwant NotFound", err)
+	}
+	// The referent doesn't exist yet
+	err = c.Push(context.Background(), []midonet.APIResource{bridge, port})
+	if !midonet.IsNotFound(err) {
+		t.Errorf("got %v\nwant NotFound", err)
+	}
+	err = c.Push(context.Background(), []midonet.APIResource{bridge, chain, port})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	// Push again; 409 should be handled by the client
+	err = c.Push(context.Background(), []midonet.APIResource{bridge, chain, port})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if !s.Exists(fmt.Sprintf("/ports/%s", portID)) {
+		t.Errorf("port doesn't exist")
+	}
+	// Deleting the bridge cascade-deletes the port
+	err = c.Delete(context.Background(), []midonet.APIResource{bridge})
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	err = c.Delete(context.Background(), []midonet.APIResource{port, chain})
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if len(s.Paths()) != 0 {
+		t.Errorf("got %v\nwant nothing", s.Paths())
+	}
+}
+
+func TestSkipNoopUpdates(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := midonet.NewClient(midonet.NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI:             s.URL,
+		MidoNetSkipNoopUpdates: true,
+	}))
+	bridgeID := uuid.New()
+	portID := uuid.New()
+	bridge := &midonet.Bridge{ID: &bridgeID, Name: "bridge"}
+	port := &midonet.Port{Parent: midonet.Parent{ID: &bridgeID}, ID: &portID, Type: "Bridge"}
+	err := c.Push(context.Background(), []midonet.APIResource{bridge, port})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	bridgePath := fmt.Sprintf("/bridges/%s", bridgeID)
+	portPath := fmt.Sprintf("/ports/%s", portID)
+
+	// Nothing changed
+	s.ResetRequests()
+	err = c.Push(context.Background(), []midonet.APIResource{bridge, port})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	expected := []string{"GET " + bridgePath, "GET " + portPath}
+	if !reflect.DeepEqual(s.Requests(), expected) {
+		t.Errorf("got %v\nwant %v", s.Requests(), expected)
+	}
+
+	// The bridge changed
+	s.ResetRequests()
+	bridge.Name = "renamed"
+	err = c.Push(context.Background(), []midonet.APIResource{bridge, port})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	expected = []string{"GET " + bridgePath, "POST /bridges", "PUT " + bridgePath, "GET " + portPath}
+	if !reflect.DeepEqual(s.Requests(), expected) {
+		t.Errorf("got %v\nwant %v", s.Requests(), expected)
+	}
+	if name := s.Get(bridgePath)["name"]; name != "renamed" {
+		t.Errorf("got %v\nwant renamed", name)
+	}
+}
+
+func TestRules(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := newClient(s)
+	chainID := uuid.New()
+	rule1 := uuid.New()
+	rule2 := uuid.New()
+	err := c.Push(context.Background(), []midonet.APIResource{
+		&midonet.Chain{ID: &chainID},
+		&midonet.Rule{Parent: midonet.Parent{ID: &chainID}, ID: &rule1, Type: "accept"},
+		&midonet.Rule{Parent: midonet.Parent{ID: &chainID}, ID: &rule2, Type: "drop"},
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	var actual []string
+	for _, r := range s.Rules(chainID.String()) {
+		actual = append(actual, r["type"].(string))
+	}
+	expected := []string{"drop", "accept"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("got %v\nwant %v", actual, expected)
+	}
+
+	// A Rule with a position
+	rule3 := uuid.New()
+	err = c.Push(context.Background(), []midonet.APIResource{
+		&midonet.Rule{Parent: midonet.Parent{ID: &chainID}, ID: &rule3, Type: "return", Position: 2},
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	actual = nil
+	for _, r := range s.Rules(chainID.String()) {
+		actual = append(actual, r["type"].(string))
+	}
+	expected = []string{"drop", "return", "accept"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("got %v\nwant %v", actual, expected)
+	}
+}
+
+func TestTables(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := newClient(s)
+	bridgeID := uuid.New()
+	portID := uuid.New()
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	err := c.Push(context.Background(), []midonet.APIResource{
+		&midonet.Bridge{ID: &bridgeID},
+		&midonet.Port{Parent: midonet.Parent{ID: &bridgeID}, ID: &portID, Type: "Bridge"},
+		&midonet.MACPort{Parent: midonet.Parent{ID: &bridgeID}, MACAddr: midonet.HardwareAddr(mac), PortID: &portID},
+		&midonet.IPv4MACPair{Parent: midonet.Parent{ID: &bridgeID}, IP: net.ParseIP("192.0.2.1"), MAC: midonet.HardwareAddr(mac)},
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	expected := []string{
+		fmt.Sprintf("/bridges/%s", bridgeID),
+		fmt.Sprintf("/bridges/%s/arp_table/192.0.2.1_02-00-00-00-00-01", bridgeID),
+		fmt.Sprintf("/bridges/%s/mac_table/02-00-00-00-00-01_%s", bridgeID, portID),
+		fmt.Sprintf("/ports/%s", portID),
+	}
+	if !reflect.DeepEqual(s.Paths(), expected) {
+		t.Errorf("got %v\nwant %v", s.Paths(), expected)
+	}
+}
+
+func TestHosts(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := newClient(s)
+	hostID := uuid.New()
+	s.AddHost(hostID, "node1")
+	id, err := midonet.NewHostResolver(c).ResolveHost(context.Background(), "node1")
+	if err != nil {
+		t.Fatalf("ResolveHost: %v", err)
+	}
+	if *id != hostID {
+		t.Errorf("got %v\nwant %v", id, hostID)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.RequireAuth("admin", "secret")
+	c := newClient(s)
+	chainID := uuid.New()
+	chain := &midonet.Chain{ID: &chainID}
+	s.FailNext("POST", "/chains", 503)
+	err := c.Push(context.Background(), []midonet.APIResource{chain})
+	if !midonet.IsServerError(err) {
+		t.Errorf("got %v\nwant ServerError", err)
+	}
+	// The client logs in again on an expired token
+	s.ExpireTokens()
+	err = c.Push(context.Background(), []midonet.APIResource{chain})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	s.RequireAuth("admin", "changed")
+	s.ExpireTokens()
+	err = c.Delete(context.Background(), []midonet.APIResource{chain})
+	if !midonet.IsUnauthorized(err) {
+		t.Errorf("got %v\nwant Unauthorized", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := newClient(s)
+	routerID := uuid.New()
+	portID := uuid.New()
+	routeID := uuid.New()
+	otherID := uuid.New()
+	router := &midonet.Router{ID: &routerID, TenantID: "tenant", Name: "router"}
+	port := &midonet.Port{Parent: midonet.Parent{ID: &routerID}, ID: &portID, Type: "Router"}
+	route := &midonet.Route{
+		Parent:           midonet.Parent{ID: &routerID},
+		ID:               &routeID,
+		DstNetworkAddr:   net.ParseIP("192.0.2.0"),
+		DstNetworkLength: 24,
+		NextHopPort:      &portID,
+		SrcNetworkAddr:   net.ParseIP("0.0.0.0"),
+		Type:             "Normal",
+	}
+	other := &midonet.Router{ID: &otherID, TenantID: "other"}
+	err := c.Push(context.Background(), []midonet.APIResource{router, port, route, other})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	snapshot, err := c.TakeSnapshot(context.Background(), "tenant")
+	if err != nil {
+		t.Fatalf("TakeSnapshot: %v", err)
+	}
+	if snapshot.Len() != 3 {
+		t.Errorf("got %d resources\nwant 3", snapshot.Len())
+	}
+	for _, res := range []midonet.APIResource{router, port, route} {
+		if !snapshot.UpToDate(res) {
+			t.Errorf("%v is not up to date", res)
+		}
+	}
+	if snapshot.UpToDate(other) {
+		t.Errorf("a resource of the other tenant is up to date")
+	}
+	router.Name = "renamed"
+	if snapshot.UpToDate(router) {
+		t.Errorf("a changed resource is up to date")
+	}
+	snapshot.Forget(port)
+	if snapshot.UpToDate(port) {
+		t.Errorf("a forgotten resource is up to date")
+	}
+}
+
+func TestPing(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.RequireAuth("admin", "secret")
+	c := newClient(s)
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+	// The first request has no token and gets 401.
+	expected := []string{"GET /system_state", "POST /login", "GET /system_state"}
+	if !reflect.DeepEqual(s.Requests(), expected) {
+		t.Errorf("got %v\nwant %v", s.Requests(), expected)
+	}
+	bad := midonet.NewClient(midonet.NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI:   s.URL,
+		MidoNetAuth:  "token",
+		MidoNetToken: "bogus",
+	}))
+	if err := bad.Ping(context.Background()); !midonet.IsUnauthorized(err) {
+		t.Errorf("got %v\nwant Unauthorized for a bad token", err)
+	}
+	s.RequireAuth("admin", "changed")
+	s.ExpireTokens()
+	if err := c.Ping(context.Background()); !midonet.IsUnauthorized(err) {
+		t.Errorf("got %v\nwant Unauthorized", err)
+	}
+	s.Close()
+	if err := c.Ping(context.Background()); err == nil {
+		t.Errorf("got nil\nwant an error")
+	}
+}
@@ -16,6 +16,7 @@
 package midonet
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/google/uuid"
@@ -33,10 +34,10 @@ func NewHostResolver(client *Client) *HostResolver {
 }
 
 // ResolveHost resolves a hostname to the corresponding MidoNet Host ID.
-func (h *HostResolver) ResolveHost(hostname string) (*uuid.UUID, error) {
+func (h *HostResolver) ResolveHost(ctx context.Context, hostname string) (*uuid.UUID, error) {
 	clog := log.WithField("hostname", hostname)
 	clog.Debug("Start resolving")
-	hosts, err := listHosts(h.client)
+	hosts, err := listHosts(ctx, h.client)
 	if err != nil {
 		clog.WithError(err).Error("listHosts")
 		return nil, err
@@ -52,9 +53,9 @@ func (h *HostResolver) ResolveHost(hostname string) (*uuid.UUID, error) {
 	return nil, fmt.Errorf("Host %s not found", hostname)
 }
 
-func listHosts(c *Client) ([]Host, error) {
+func listHosts(ctx context.Context, c *Client) ([]Host, error) {
 	var hosts []Host
-	_, err := c.List(&hosts)
+	_, err := c.List(ctx, &hosts)
 	if err != nil {
 		return nil, err
 	}
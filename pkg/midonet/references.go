@@ -0,0 +1,71 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package midonet
+
+import (
+	"reflect"
+
+	"github.com/google/uuid"
+)
+
+// referenceFields are the names of the fields which refer to
+// other resources.
+var referenceFields = []string{
+	"InboundFilterID",
+	"OutboundFilterID",
+	"JumpChainID",
+	"NextHopPort",
+	"PortID",
+	"PeerID",
+	"HostID",
+}
+
+// ResourceID returns the ID of the given resource, or nil if the kind
+// of the resource doesn't have its own ID.  (e.g. PortLink)
+func ResourceID(res APIResource) *uuid.UUID {
+	v := reflect.ValueOf(res).Elem()
+	// Note: Ignore Parent.ID
+	f, ok := v.Type().FieldByName("ID")
+	if !ok || len(f.Index) != 1 {
+		return nil
+	}
+	id, ok := v.FieldByIndex(f.Index).Interface().(*uuid.UUID)
+	if !ok {
+		return nil
+	}
+	return id
+}
+
+// References returns the IDs of the resources which the given resource
+// refers to, including its parent.  The referents need to exist before
+// the resource is created.
+func References(res APIResource) []uuid.UUID {
+	var ids []uuid.UUID
+	if p, ok := res.(HasParent); ok && p.GetParent() != nil {
+		ids = append(ids, *p.GetParent())
+	}
+	v := reflect.ValueOf(res).Elem()
+	for _, name := range referenceFields {
+		f := v.FieldByName(name)
+		if !f.IsValid() {
+			continue
+		}
+		if id, ok := f.Interface().(*uuid.UUID); ok && id != nil {
+			ids = append(ids, *id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,55 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package midonet
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestResourceID(t *testing.T) {
+	id := uuid.New()
+	parentID := uuid.New()
+	if actual := ResourceID(&Rule{Parent: Parent{ID: &parentID}, ID: &id}); *actual != id {
+		t.Errorf("got %v\nwant %v", actual, id)
+	}
+	if actual := ResourceID(&PortLink{Parent: Parent{ID: &parentID}}); actual != nil {
+		t.Errorf("got %v\nwant nil", actual)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	id := uuid.New()
+	chainID := uuid.New()
+	jumpID := uuid.New()
+	actual := References(&Rule{Parent: Parent{ID: &chainID}, ID: &id, JumpChainID: &jumpID})
+	expected := []uuid.UUID{chainID, jumpID}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("got %v\nwant %v", actual, expected)
+	}
+	routerID := uuid.New()
+	portID := uuid.New()
+	actual = References(&Route{Parent: Parent{ID: &routerID}, ID: &id, NextHopPort: &portID})
+	expected = []uuid.UUID{routerID, portID}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("got %v\nwant %v", actual, expected)
+	}
+	if actual := References(&Chain{ID: &id}); actual != nil {
+		t.Errorf("got %v\nwant nil", actual)
+	}
+}
@@ -139,6 +139,7 @@ type Router struct {
 	Name             string     `json:"name,omitempty"`
 	InboundFilterID  *uuid.UUID `json:"inboundFilterId,omitempty"`
 	OutboundFilterID *uuid.UUID `json:"outboundFilterId,omitempty"`
+	ASNumber         int        `json:"asNumber,omitempty"`
 }
 
 func (*Router) MediaType() string {
@@ -265,6 +266,54 @@ func (res *Route) Path(op string) string {
 	}
 }
 
+// BGPPeer implements https://docs.midonet.org/docs/v5.4/en/rest-api/content/bgp-peer.html
+type BGPPeer struct {
+	midonetResource
+	Parent
+	ID       *uuid.UUID `json:"id,omitempty"`
+	ASNumber int        `json:"asNumber"`
+	Address  net.IP     `json:"address"`
+}
+
+func (*BGPPeer) MediaType() string {
+	return "application/vnd.org.midonet.BgpPeer-v1+json"
+}
+
+func (res *BGPPeer) Path(op string) string {
+	switch op {
+	case "POST":
+		return fmt.Sprintf("/routers/%s/bgp_peers", res.Parent.ID)
+	case "PUT", "DELETE", "GET":
+		return fmt.Sprintf("/bgp_peers/%s", res.ID)
+	default:
+		return ""
+	}
+}
+
+// BGPNetwork implements https://docs.midonet.org/docs/v5.4/en/rest-api/content/bgp-network.html
+type BGPNetwork struct {
+	midonetResource
+	Parent
+	ID            *uuid.UUID `json:"id,omitempty"`
+	SubnetAddress net.IP     `json:"subnetAddress"`
+	SubnetLength  int        `json:"subnetLength"`
+}
+
+func (*BGPNetwork) MediaType() string {
+	return "application/vnd.org.midonet.BgpNetwork-v1+json"
+}
+
+func (res *BGPNetwork) Path(op string) string {
+	switch op {
+	case "POST":
+		return fmt.Sprintf("/routers/%s/bgp_networks", res.Parent.ID)
+	case "DELETE", "GET":
+		return fmt.Sprintf("/bgp_networks/%s", res.ID)
+	default:
+		return ""
+	}
+}
+
 // Chain implements https://docs.midonet.org/docs/v5.4/en/rest-api/content/chain.html
 type Chain struct {
 	midonetResource
@@ -294,6 +343,8 @@ type Rule struct {
 	Parent
 	ID           *uuid.UUID `json:"id,omitempty"`
 	Type         string     `json:"type"`
+	DLSrc        string     `json:"dlSrc,omitempty"`
+	DLSrcMask    string     `json:"dlSrcMask,omitempty"`
 	DLType       int        `json:"dlType,omitempty"`
 	NWDstAddress string     `json:"nwDstAddress,omitempty"`
 	NWDstLength  int        `json:"nwDstLength,omitempty"`
@@ -303,6 +354,11 @@ type Rule struct {
 	TPDst        *PortRange `json:"tpDst,omitempty"`
 	TPSrc        *PortRange `json:"tpSrc,omitempty"`
 
+	// The Ports the traffic came in through the device
+	InPorts []uuid.UUID `json:"inPorts,omitempty"`
+
+	MatchReturnFlow bool `json:"matchReturnFlow,omitempty"`
+
 	// JUMP
 	JumpChainID *uuid.UUID `json:"jumpChainId,omitempty"`
 
@@ -311,6 +367,10 @@ type Rule struct {
 
 	// DNAT, SNAT
 	NATTargets *[]NATTarget `json:"natTargets,omitempty"`
+
+	// The position in the Chain, starting with 1.  MidoNet API
+	// inserts the Rule at the head of the Chain if it's omitted.
+	Position int `json:"position,omitempty"`
 }
 
 func (*Rule) MediaType() string {
@@ -0,0 +1,125 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package midonet
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// Snapshot is a copy of the resources owned by a tenant on MidoNet API,
+// taken with a few collection listings.  It's used to find the resources
+// which are already up to date without a GET for each of them.
+// A Snapshot is not goroutine-safe.
+type Snapshot struct {
+	// objects maps the paths for GET to the decoded JSON objects.
+	objects map[string]map[string]interface{}
+}
+
+// TakeSnapshot lists Routers, Bridges and Chains owned by the given
+// tenant, and Ports, Routes and Rules in them.
+// Note: The other resources, e.g. MAC table entries, are not in
+// the Snapshot.
+func (c *Client) TakeSnapshot(ctx context.Context, tenant string) (*Snapshot, error) {
+	s := &Snapshot{
+		objects: make(map[string]map[string]interface{}),
+	}
+	query := "?tenant_id=" + url.QueryEscape(tenant)
+	routers, err := s.addCollection(ctx, c, "/routers"+query, routerCollectionMediaType, "/routers/%s")
+	if err != nil {
+		return nil, err
+	}
+	bridges, err := s.addCollection(ctx, c, "/bridges"+query, bridgeCollectionMediaType, "/bridges/%s")
+	if err != nil {
+		return nil, err
+	}
+	chains, err := s.addCollection(ctx, c, "/chains"+query, chainCollectionMediaType, "/chains/%s")
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range routers {
+		path := fmt.Sprintf("/routers/%s/ports", id)
+		if _, err := s.addCollection(ctx, c, path, portCollectionMediaType, "/ports/%s"); err != nil {
+			return nil, err
+		}
+		path = fmt.Sprintf("/routers/%s/routes", id)
+		if _, err := s.addCollection(ctx, c, path, routeCollectionMediaType, "/routes/%s"); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range bridges {
+		path := fmt.Sprintf("/bridges/%s/ports", id)
+		if _, err := s.addCollection(ctx, c, path, portCollectionMediaType, "/ports/%s"); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range chains {
+		path := fmt.Sprintf("/chains/%s/rules", id)
+		if _, err := s.addCollection(ctx, c, path, ruleCollectionMediaType, "/rules/%s"); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// addCollection lists the collection and adds the items to the Snapshot
+// with the paths made of the given format and their IDs.
+// It returns the IDs.
+func (s *Snapshot) addCollection(ctx context.Context, c *Client, path string, mediaType string, itemFormat string) ([]string, error) {
+	var items []map[string]interface{}
+	if _, err := c.list(ctx, path, mediaType, &items); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, item := range items {
+		id, ok := item["id"].(string)
+		if !ok {
+			continue
+		}
+		s.objects[fmt.Sprintf(itemFormat, id)] = item
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Len returns the number of resources in the Snapshot.
+func (s *Snapshot) Len() int {
+	return len(s.objects)
+}
+
+// UpToDate returns true if the Snapshot has the resource and it's same
+// as the given one, in the same way as CheckDrift.
+func (s *Snapshot) UpToDate(res APIResource) bool {
+	if !checkable(res) {
+		return false
+	}
+	actual, ok := s.objects[res.Path("GET")]
+	if !ok {
+		return false
+	}
+	fields, err := diffFields(res, actual)
+	return err == nil && fields == nil
+}
+
+// Forget removes the resource from the Snapshot.  It should be called
+// once the resource might have been changed since the Snapshot was
+// taken.
+func (s *Snapshot) Forget(res APIResource) {
+	if path := res.Path("GET"); path != "" {
+		delete(s.objects, path)
+	}
+}
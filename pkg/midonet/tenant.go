@@ -0,0 +1,95 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package midonet
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+const (
+	bridgeCollectionMediaType = "application/vnd.org.midonet.collection.Bridge-v4+json"
+	routerCollectionMediaType = "application/vnd.org.midonet.collection.Router-v3+json"
+	chainCollectionMediaType  = "application/vnd.org.midonet.collection.Chain-v1+json"
+	portCollectionMediaType   = "application/vnd.org.midonet.collection.Port-v3+json"
+	ruleCollectionMediaType   = "application/vnd.org.midonet.collection.Rule-v2+json"
+	routeCollectionMediaType  = "application/vnd.org.midonet.collection.Route-v1+json"
+)
+
+// ListTenantResources lists Routers, Bridges and Chains owned by
+// the given tenant, and Ports and Rules in them.
+// The parents of Ports and Rules are set.
+// The result is ordered so that it's safe to delete them in the order.
+// (Rules, Ports, Chains, Bridges, and then Routers)
+func (c *Client) ListTenantResources(ctx context.Context, tenant string) ([]APIResource, error) {
+	query := "?tenant_id=" + url.QueryEscape(tenant)
+	var routers []*Router
+	if _, err := c.list(ctx, "/routers"+query, routerCollectionMediaType, &routers); err != nil {
+		return nil, err
+	}
+	var bridges []*Bridge
+	if _, err := c.list(ctx, "/bridges"+query, bridgeCollectionMediaType, &bridges); err != nil {
+		return nil, err
+	}
+	var chains []*Chain
+	if _, err := c.list(ctx, "/chains"+query, chainCollectionMediaType, &chains); err != nil {
+		return nil, err
+	}
+	var rules []APIResource
+	for _, chain := range chains {
+		var rs []*Rule
+		path := fmt.Sprintf("/chains/%s/rules", chain.ID)
+		if _, err := c.list(ctx, path, ruleCollectionMediaType, &rs); err != nil {
+			return nil, err
+		}
+		for _, r := range rs {
+			r.Parent.ID = chain.ID
+			rules = append(rules, r)
+		}
+	}
+	var ports []APIResource
+	parents := make(map[string]*uuid.UUID)
+	for _, r := range routers {
+		parents[fmt.Sprintf("/routers/%s/ports", r.ID)] = r.ID
+	}
+	for _, b := range bridges {
+		parents[fmt.Sprintf("/bridges/%s/ports", b.ID)] = b.ID
+	}
+	for path, parentID := range parents {
+		var ps []*Port
+		if _, err := c.list(ctx, path, portCollectionMediaType, &ps); err != nil {
+			return nil, err
+		}
+		for _, p := range ps {
+			p.Parent.ID = parentID
+			ports = append(ports, p)
+		}
+	}
+	resources := append(rules, ports...)
+	for _, chain := range chains {
+		resources = append(resources, chain)
+	}
+	for _, b := range bridges {
+		resources = append(resources, b)
+	}
+	for _, r := range routers {
+		resources = append(resources, r)
+	}
+	return resources, nil
+}
@@ -16,6 +16,7 @@
 package nodeannotator
 
 import (
+	"context"
 	"encoding/json"
 
 	log "github.com/sirupsen/logrus"
@@ -33,7 +34,7 @@ import (
 )
 
 type annotator interface {
-	getData(*v1.Node) (string, error)
+	getData(context.Context, *v1.Node) (string, error)
 }
 
 type annotatorHandler struct {
@@ -41,6 +42,9 @@ type annotatorHandler struct {
 	recorder   record.EventRecorder
 	config     *midonet.Config
 	annotators map[string]annotator
+
+	// ctx is canceled when the controller fails to stop in time.
+	ctx context.Context
 }
 
 func newHandler(kc *kubernetes.Clientset, recorder record.EventRecorder, config *midonet.Config) *annotatorHandler {
@@ -57,6 +61,7 @@ func newHandler(kc *kubernetes.Clientset, recorder record.EventRecorder, config
 			converter.TunnelZoneIDAnnotation:     &defaultTunnelZoneAnnotator{},
 			converter.TunnelEndpointIPAnnotation: &tunnelEndpointIPAnnotator{},
 		},
+		ctx: context.Background(),
 	}
 }
 
@@ -78,7 +83,7 @@ func (h *annotatorHandler) Update(key string, gvk schema.GroupVersionKind, obj i
 			/* nothing to do */
 			continue
 		}
-		data, err := a.getData(n)
+		data, err := a.getData(h.ctx, n)
 		if err != nil {
 			return err
 		}
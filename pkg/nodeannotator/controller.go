@@ -33,5 +33,7 @@ func NewController(si informers.SharedInformerFactory, msi mninformers.SharedInf
 	informer := si.Core().V1().Nodes().Informer()
 	handler := newHandler(kc, recorder, config)
 	gvk := v1.SchemeGroupVersion.WithKind("Node")
-	return controller.NewController(gvk, informer, handler)
+	c := controller.NewController(gvk, informer, handler)
+	handler.ctx = c.Context()
+	return c
 }
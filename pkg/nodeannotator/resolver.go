@@ -16,6 +16,8 @@
 package nodeannotator
 
 import (
+	"context"
+
 	"k8s.io/api/core/v1"
 
 	"github.com/midonet/midonet-kubernetes/pkg/midonet"
@@ -25,8 +27,8 @@ type hostIDAnnotator struct {
 	resolver *midonet.HostResolver
 }
 
-func (a *hostIDAnnotator) getData(n *v1.Node) (string, error) {
-	id, err := a.resolver.ResolveHost(n.ObjectMeta.Name)
+func (a *hostIDAnnotator) getData(ctx context.Context, n *v1.Node) (string, error) {
+	id, err := a.resolver.ResolveHost(ctx, n.ObjectMeta.Name)
 	if err != nil {
 		return "", err
 	}
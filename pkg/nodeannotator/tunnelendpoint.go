@@ -16,6 +16,7 @@
 package nodeannotator
 
 import (
+	"context"
 	"fmt"
 	"net"
 
@@ -27,7 +28,7 @@ import (
 type tunnelEndpointIPAnnotator struct {
 }
 
-func (a *tunnelEndpointIPAnnotator) getData(n *v1.Node) (string, error) {
+func (a *tunnelEndpointIPAnnotator) getData(ctx context.Context, n *v1.Node) (string, error) {
 	for _, addr := range n.Status.Addresses {
 		typ := addr.Type
 		// REVISIT: How about ExternalIP?
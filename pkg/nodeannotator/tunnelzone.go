@@ -16,13 +16,15 @@
 package nodeannotator
 
 import (
+	"context"
+
 	"k8s.io/api/core/v1"
 )
 
 type defaultTunnelZoneAnnotator struct {
 }
 
-func (a *defaultTunnelZoneAnnotator) getData(n *v1.Node) (string, error) {
+func (a *defaultTunnelZoneAnnotator) getData(ctx context.Context, n *v1.Node) (string, error) {
 	// Note: An empty string mean the default auto-created tunnel zone.
 	// We shouldn't return DefaultTunnelZoneID() here because it would
 	// break the TranslationVersion mechanism.
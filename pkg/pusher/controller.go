@@ -16,8 +16,11 @@
 package pusher
 
 import (
+	"github.com/prometheus/client_golang/prometheus"
+
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 
 	"github.com/midonet/midonet-kubernetes/pkg/apis/midonet/v1"
@@ -29,9 +32,54 @@ import (
 )
 
 // NewController creates a pusher controller.
-func NewController(si informers.SharedInformerFactory, msi mninformers.SharedInformerFactory, kc *kubernetes.Clientset, mc *mncli.Clientset, recorder record.EventRecorder, _ *converter.Config, config *midonet.Config) *controller.Controller {
+func NewController(si informers.SharedInformerFactory, msi mninformers.SharedInformerFactory, kc *kubernetes.Clientset, mc *mncli.Clientset, recorder record.EventRecorder, converterConfig *converter.Config, config *midonet.Config) *controller.Controller {
 	informer := msi.Midonet().V1().Translations().Informer()
-	handler := newHandler(mc, recorder, config)
+	informer.AddIndexers(cache.Indexers{providedIDIndex: providedIDs})
+	handler := newHandler(mc, recorder, config, informer.GetIndexer())
 	gvk := v1.SchemeGroupVersion.WithKind("Translation")
-	return controller.NewController(gvk, informer, handler)
+	c := controller.NewController(gvk, informer, handler)
+	handler.deps.queue = c.GetQueue()
+	handler.ctx = c.Context()
+	if converterConfig.BulkResync {
+		handler.resync = newBulkResync(config, converterConfig.Tenant)
+	}
+	c.SetShardFunc(newShardFunc(informer.GetStore()))
+	prometheus.MustRegister(newTranslationCollector(informer.GetStore()))
+	if converterConfig.DriftCheckInterval > 0 {
+		checker := newDriftChecker(informer.GetStore(), recorder, config, converterConfig)
+		c.AddBackgroundTask(func(stop <-chan struct{}) {
+			checker.run(informer, converterConfig.DriftCheckInterval, stop)
+		})
+	}
+	if converterConfig.GCInterval > 0 {
+		gc := newGarbageCollector(informer.GetStore(), config, converterConfig)
+		c.AddBackgroundTask(func(stop <-chan struct{}) {
+			gc.run(informer, converterConfig.GCInterval, stop)
+		})
+	}
+	return c
+}
+
+// newShardFunc returns a ShardFunc which puts Translations for the same
+// Kubernetes resource into the same shard, so that they are pushed in
+// the order they were queued.  E.g. Rules in a Chain of a Service.
+// Global Translations are in a shard.
+// Note: Once a Translation is removed from the store, its key is
+// sharded by itself.  It's fine because the pusher has nothing to do
+// for such keys.
+func newShardFunc(store cache.Store) controller.ShardFunc {
+	return func(key string) string {
+		obj, exists, err := store.GetByKey(key)
+		if err != nil || !exists {
+			return key
+		}
+		labels := obj.(*v1.Translation).ObjectMeta.Labels
+		if uid, ok := labels[converter.OwnerUIDLabel]; ok {
+			return uid
+		}
+		if _, ok := labels[converter.GlobalLabel]; ok {
+			return converter.GlobalLabel
+		}
+		return key
+	}
 }
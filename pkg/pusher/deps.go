@@ -0,0 +1,175 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pusher
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	mnv1 "github.com/midonet/midonet-kubernetes/pkg/apis/midonet/v1"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+const providedIDIndex = "midonet-id"
+
+// providedIDs is an IndexFunc to find the Translation which has
+// the backend resource with the given ID.
+func providedIDs(obj interface{}) ([]string, error) {
+	tr := obj.(*mnv1.Translation)
+	var ids []string
+	for _, r := range tr.Resources {
+		res, err := midonet.FromAPI(r)
+		if err != nil {
+			// Note: Returning an error here would make the indexer panic.
+			log.WithError(err).Error("FromAPI")
+			continue
+		}
+		if id := midonet.ResourceID(res); id != nil {
+			ids = append(ids, id.String())
+		}
+	}
+	return ids, nil
+}
+
+// dependencyTracker makes a Translation wait for the Translations which
+// have its referents, rather than letting the backend reject it.
+// The waiting Translation is queued again when the Translation it's
+// waiting for is synced or deleted.
+type dependencyTracker struct {
+	indexer cache.Indexer
+	queue   workqueue.Interface
+
+	mu sync.Mutex
+	// synced maps the key of a synced Translation to its generation.
+	synced    map[string]int64
+	waitingOn map[string]string
+}
+
+func newDependencyTracker(indexer cache.Indexer) *dependencyTracker {
+	return &dependencyTracker{
+		indexer:   indexer,
+		synced:    make(map[string]int64),
+		waitingOn: make(map[string]string),
+	}
+}
+
+// waitFor returns the key of a Translation which the given Translation
+// should wait for, or "" if it can be pushed now.
+func (d *dependencyTracker) waitFor(key string, resources []midonet.APIResource) string {
+	own := make(map[uuid.UUID]bool)
+	for _, res := range resources {
+		if id := midonet.ResourceID(res); id != nil {
+			own[*id] = true
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.waitingOn, key)
+	for _, res := range resources {
+		for _, id := range midonet.References(res) {
+			if own[id] {
+				continue
+			}
+			objs, err := d.indexer.ByIndex(providedIDIndex, id.String())
+			if err != nil {
+				log.WithError(err).Error("ByIndex")
+				continue
+			}
+			for _, obj := range objs {
+				provider, err := cache.MetaNamespaceKeyFunc(obj)
+				if err != nil || provider == key {
+					continue
+				}
+				providerTr := obj.(*mnv1.Translation)
+				if d.isSynced(provider, providerTr) || inSync(providerTr) {
+					continue
+				}
+				if d.cycle(key, provider) {
+					// Let the backend decide.
+					continue
+				}
+				d.waitingOn[key] = provider
+				return provider
+			}
+		}
+	}
+	return ""
+}
+
+// cycle returns true if the provider is waiting for the key,
+// directly or indirectly.
+func (d *dependencyTracker) cycle(key, provider string) bool {
+	seen := make(map[string]bool)
+	for k := provider; k != "" && !seen[k]; k = d.waitingOn[k] {
+		if k == key {
+			return true
+		}
+		seen[k] = true
+	}
+	return false
+}
+
+// isSynced returns true if the given generation of the Translation
+// has been synced.
+// Note: A synced Translation with a new generation is pending until
+// its Update is handled.
+func (d *dependencyTracker) isSynced(key string, tr *mnv1.Translation) bool {
+	generation, ok := d.synced[key]
+	return ok && generation == tr.ObjectMeta.Generation
+}
+
+// setSynced records whether the Translation is synced or not.
+func (d *dependencyTracker) setSynced(key string, tr *mnv1.Translation, synced bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !synced {
+		delete(d.synced, key)
+		return
+	}
+	d.synced[key] = tr.ObjectMeta.Generation
+	d.kickWaiters(key)
+}
+
+// forget forgets the deleted Translation.  The Translations waiting
+// for it are queued so that they fail in the usual way.
+func (d *dependencyTracker) forget(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.synced, key)
+	delete(d.waitingOn, key)
+	d.kickWaiters(key)
+}
+
+func (d *dependencyTracker) kickWaiters(key string) {
+	for k, provider := range d.waitingOn {
+		if provider != key {
+			continue
+		}
+		delete(d.waitingOn, k)
+		if d.queue != nil {
+			log.WithFields(log.Fields{
+				"key":      k,
+				"provider": key,
+			}).Debug("Kicking a waiting Translation")
+			d.queue.Add(k)
+		}
+	}
+}
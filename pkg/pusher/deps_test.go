@@ -0,0 +1,160 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pusher
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	mnv1 "github.com/midonet/midonet-kubernetes/pkg/apis/midonet/v1"
+	mnfake "github.com/midonet/midonet-kubernetes/pkg/client/clientset/versioned/fake"
+	"github.com/midonet/midonet-kubernetes/pkg/config"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet/fake"
+)
+
+func TestDependency(t *testing.T) {
+	s := fake.NewServer()
+	defer s.Close()
+	bridgeID := uuid.New()
+	portID := uuid.New()
+	bridgeTr := &mnv1.Translation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "kube-system",
+			Name:       "bridge",
+			Generation: 1,
+		},
+		Resources: []mnv1.BackendResource{
+			toAPI(t, &midonet.Bridge{ID: &bridgeID}),
+		},
+	}
+	portTr := &mnv1.Translation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "kube-system",
+			Name:       "port",
+			Generation: 1,
+		},
+		Resources: []mnv1.BackendResource{
+			toAPI(t, &midonet.Port{Parent: midonet.Parent{ID: &bridgeID}, ID: &portID, Type: "Bridge"}),
+		},
+	}
+	mc := mnfake.NewSimpleClientset(bridgeTr, portTr)
+	config := midonet.NewConfigFromEnvConfig(&config.Config{MidoNetAPI: s.URL})
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{providedIDIndex: providedIDs})
+	indexer.Add(bridgeTr)
+	indexer.Add(portTr)
+	h := newHandler(mc, record.NewFakeRecorder(10), config, indexer)
+	queue := workqueue.New()
+	defer queue.ShutDown()
+	h.deps.queue = queue
+	gvk := mnv1.SchemeGroupVersion.WithKind("Translation")
+
+	// The port waits for the bridge without touching the backend
+	err := h.Update("kube-system/port", gvk, portTr)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if len(s.Requests()) != 0 {
+		t.Errorf("got %v\nwant no requests", s.Requests())
+	}
+	tr, _ := mc.MidonetV1().Translations("kube-system").Get("port", metav1.GetOptions{})
+	if tr.Status.Phase != mnv1.TranslationPending {
+		t.Errorf("unexpected status %v", tr.Status)
+	}
+
+	// Pushing the bridge kicks the port
+	err = h.Update("kube-system/bridge", gvk, bridgeTr)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if queue.Len() != 1 {
+		t.Fatalf("got %d queued\nwant 1", queue.Len())
+	}
+	key, _ := queue.Get()
+	if key != "kube-system/port" {
+		t.Errorf("got %v\nwant kube-system/port", key)
+	}
+	queue.Done(key)
+	err = h.Update("kube-system/port", gvk, portTr)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !s.Exists("/ports/" + portID.String()) {
+		t.Errorf("port doesn't exist")
+	}
+}
+
+func TestDependencyGeneration(t *testing.T) {
+	bridgeID := uuid.New()
+	portID := uuid.New()
+	bridgeTr := &mnv1.Translation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "kube-system",
+			Name:       "bridge",
+			Generation: 1,
+		},
+		Resources: []mnv1.BackendResource{
+			toAPI(t, &midonet.Bridge{ID: &bridgeID}),
+		},
+	}
+	port := []midonet.APIResource{
+		&midonet.Port{Parent: midonet.Parent{ID: &bridgeID}, ID: &portID, Type: "Bridge"},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{providedIDIndex: providedIDs})
+	indexer.Add(bridgeTr)
+	d := newDependencyTracker(indexer)
+
+	d.setSynced("kube-system/bridge", bridgeTr, true)
+	if provider := d.waitFor("kube-system/port", port); provider != "" {
+		t.Errorf("got %q\nwant no wait", provider)
+	}
+
+	// The synced generation is superseded by a new one
+	newBridgeTr := bridgeTr.DeepCopy()
+	newBridgeTr.ObjectMeta.Generation = 2
+	indexer.Update(newBridgeTr)
+	if provider := d.waitFor("kube-system/port", port); provider != "kube-system/bridge" {
+		t.Errorf("got %q\nwant kube-system/bridge", provider)
+	}
+
+	d.setSynced("kube-system/bridge", newBridgeTr, true)
+	if provider := d.waitFor("kube-system/port", port); provider != "" {
+		t.Errorf("got %q\nwant no wait", provider)
+	}
+}
+
+func TestDependencyCycle(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	d := newDependencyTracker(indexer)
+	d.waitingOn["a"] = "b"
+	d.waitingOn["b"] = "c"
+	if !d.cycle("c", "a") {
+		t.Errorf("cycle not detected")
+	}
+	if d.cycle("d", "a") {
+		t.Errorf("unexpected cycle")
+	}
+	d.waitingOn["c"] = "a"
+	if d.cycle("d", "a") {
+		t.Errorf("unexpected cycle")
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pusher
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	mnv1 "github.com/midonet/midonet-kubernetes/pkg/apis/midonet/v1"
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+var (
+	driftCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "midonet_kube_controllers",
+			Subsystem: "pusher",
+			Name:      "drifted_resources_total",
+			Help:      "Number of backend resources found different from Translations",
+		},
+		[]string{"kind"},
+	)
+
+	repairCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "midonet_kube_controllers",
+			Subsystem: "pusher",
+			Name:      "repaired_resources_total",
+			Help:      "Number of drifted backend resources repaired",
+		},
+		[]string{"kind"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(driftCount)
+	prometheus.MustRegister(repairCount)
+}
+
+// driftChecker periodically compares Synced Translations with
+// the backend.
+type driftChecker struct {
+	store    cache.Store
+	client   *midonet.Client
+	recorder record.EventRecorder
+	config   *converter.Config
+}
+
+func newDriftChecker(store cache.Store, recorder record.EventRecorder, config *midonet.Config, converterConfig *converter.Config) *driftChecker {
+	return &driftChecker{
+		store:    store,
+		client:   midonet.NewClient(config),
+		recorder: recorder,
+		config:   converterConfig,
+	}
+}
+
+func (d *driftChecker) run(informer cache.SharedIndexInformer, interval time.Duration, stop <-chan struct{}) {
+	if !cache.WaitForCacheSync(stop, informer.HasSynced) {
+		return
+	}
+	ctx, cancel := contextForStop(stop)
+	defer cancel()
+	wait.Until(func() { d.checkAll(ctx) }, interval, stop)
+}
+
+func (d *driftChecker) checkAll(ctx context.Context) {
+	log.Debug("Start checking drift")
+	for _, obj := range d.store.List() {
+		tr := obj.(*mnv1.Translation)
+		// Leave the others to pusherHandler.  In particular,
+		// a Translation with a new generation is being pushed.
+		if tr.ObjectMeta.DeletionTimestamp != nil || !inSync(tr) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		d.check(ctx, tr)
+	}
+	log.Debug("Done checking drift")
+}
+
+func (d *driftChecker) check(ctx context.Context, tr *mnv1.Translation) {
+	clog := log.WithFields(log.Fields{
+		"namespace": tr.ObjectMeta.Namespace,
+		"name":      tr.ObjectMeta.Name,
+	})
+	var resources []midonet.APIResource
+	for _, r := range tr.Resources {
+		res, err := midonet.FromAPI(r)
+		if err != nil {
+			clog.WithError(err).Error("FromAPI")
+			return
+		}
+		resources = append(resources, res)
+	}
+	for i, res := range resources {
+		r := tr.Resources[i]
+		drift, err := d.client.CheckDrift(ctx, res)
+		if err != nil {
+			clog.WithError(err).Warn("Failed to check drift")
+			return
+		}
+		if drift == nil {
+			continue
+		}
+		var what string
+		if drift.Missing {
+			what = "missing"
+		} else {
+			what = "different " + strings.Join(drift.Fields, ",")
+		}
+		clog.WithFields(log.Fields{
+			"index": i,
+			"kind":  r.Kind,
+			"drift": what,
+		}).Warn("Drift detected")
+		driftCount.With(prometheus.Labels{"kind": r.Kind}).Inc()
+		d.recorder.Eventf(tr, v1.EventTypeWarning, "TranslationDriftDetected", "Backend resource %d (%s) is %s", i, r.Kind, what)
+		if !d.config.Reloadable().DriftRepair {
+			continue
+		}
+		if rule, ok := res.(*midonet.Rule); ok && rule.Parent.ID != nil {
+			// Keep the order of the Rules in the Chain.
+			err = d.client.RepairRule(ctx, rule, chainRules(resources, *rule.Parent.ID), drift)
+		} else {
+			err = d.client.Repair(ctx, res, drift)
+		}
+		if err != nil {
+			d.recorder.Eventf(tr, v1.EventTypeWarning, "TranslationDriftRepairError", "Backend resource %d (%s) repair failed with error %v", i, r.Kind, err)
+			return
+		}
+		repairCount.With(prometheus.Labels{"kind": r.Kind}).Inc()
+		d.recorder.Eventf(tr, v1.EventTypeNormal, "TranslationDriftRepaired", "Backend resource %d (%s) repaired", i, r.Kind)
+	}
+}
+
+// chainRules returns the Rules in the given Chain among the resources,
+// in the order of the resources.
+func chainRules(resources []midonet.APIResource, chainID uuid.UUID) []*midonet.Rule {
+	var rules []*midonet.Rule
+	for _, res := range resources {
+		rule, ok := res.(*midonet.Rule)
+		if ok && rule.Parent.ID != nil && *rule.Parent.ID == chainID {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
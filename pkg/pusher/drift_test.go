@@ -0,0 +1,212 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pusher
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	mnv1 "github.com/midonet/midonet-kubernetes/pkg/apis/midonet/v1"
+	"github.com/midonet/midonet-kubernetes/pkg/config"
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet/fake"
+)
+
+func TestDrift(t *testing.T) {
+	s := fake.NewServer()
+	defer s.Close()
+	config := midonet.NewConfigFromEnvConfig(&config.Config{MidoNetAPI: s.URL})
+	bridgeID := uuid.New()
+	chainID := uuid.New()
+	ruleID := uuid.New()
+	resources := []midonet.APIResource{
+		&midonet.Bridge{ID: &bridgeID, Name: "b"},
+		&midonet.Chain{ID: &chainID, Name: "c"},
+		&midonet.Rule{Parent: midonet.Parent{ID: &chainID}, ID: &ruleID, Type: "accept"},
+	}
+	err := midonet.NewClient(config).Push(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	tr := &mnv1.Translation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "kube-system",
+			Name:       "test",
+			Generation: 1,
+		},
+		Status: mnv1.TranslationStatus{
+			Phase:              mnv1.TranslationSynced,
+			ObservedGeneration: 1,
+		},
+	}
+	for _, res := range resources {
+		tr.Resources = append(tr.Resources, toAPI(t, res.(converter.BackendResource)))
+	}
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	store.Add(tr)
+	recorder := record.NewFakeRecorder(10)
+
+	// Detect only
+	s.Modify(fmt.Sprintf("/bridges/%s", bridgeID), map[string]interface{}{"name": "edited"})
+	s.Modify(fmt.Sprintf("/rules/%s", ruleID), map[string]interface{}{"type": "drop"})
+	newDriftChecker(store, recorder, config, &converter.Config{}).checkAll(context.Background())
+	if len(recorder.Events) != 2 {
+		t.Fatalf("got %d events\nwant 2", len(recorder.Events))
+	}
+	expected := "Warning TranslationDriftDetected Backend resource 0 (Bridge) is different name"
+	if e := <-recorder.Events; e != expected {
+		t.Errorf("got %q\nwant %q", e, expected)
+	}
+	<-recorder.Events
+
+	// Repair
+	s.Remove(fmt.Sprintf("/chains/%s", chainID))
+	cfg := &converter.Config{}
+	cfg.SetReloadable(converter.ReloadableConfig{DriftRepair: true})
+	newDriftChecker(store, recorder, config, cfg).checkAll(context.Background())
+	if s.Get(fmt.Sprintf("/bridges/%s", bridgeID))["name"] != "b" {
+		t.Errorf("Bridge not repaired")
+	}
+	rules := s.Rules(chainID.String())
+	if len(rules) != 1 || rules[0]["type"] != "accept" {
+		t.Errorf("Rule not repaired: %v", rules)
+	}
+	// Detected and repaired: Bridge, Chain, Rule
+	if len(recorder.Events) != 6 {
+		t.Errorf("got %d events\nwant 6", len(recorder.Events))
+	}
+}
+
+func TestDriftRuleOrder(t *testing.T) {
+	s := fake.NewServer()
+	defer s.Close()
+	config := midonet.NewConfigFromEnvConfig(&config.Config{MidoNetAPI: s.URL})
+	chainID := uuid.New()
+	resources := []midonet.APIResource{
+		&midonet.Chain{ID: &chainID, Name: "c"},
+	}
+	var ruleIDs []string
+	for _, ruleType := range []string{"drop", "jump", "accept"} {
+		ruleID := uuid.New()
+		resources = append(resources, &midonet.Rule{Parent: midonet.Parent{ID: &chainID}, ID: &ruleID, Type: ruleType})
+		ruleIDs = append(ruleIDs, ruleID.String())
+	}
+	err := midonet.NewClient(config).Push(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	tr := &mnv1.Translation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "kube-system",
+			Name:       "test",
+			Generation: 1,
+		},
+		Status: mnv1.TranslationStatus{
+			Phase:              mnv1.TranslationSynced,
+			ObservedGeneration: 1,
+		},
+	}
+	for _, res := range resources {
+		tr.Resources = append(tr.Resources, toAPI(t, res.(converter.BackendResource)))
+	}
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	store.Add(tr)
+	cfg := &converter.Config{}
+	cfg.SetReloadable(converter.ReloadableConfig{DriftRepair: true})
+
+	// A Rule of another Translation in the same Chain
+	otherID := uuid.New()
+	err = midonet.NewClient(config).Push(context.Background(), []midonet.APIResource{
+		&midonet.Rule{Parent: midonet.Parent{ID: &chainID}, ID: &otherID, Type: "accept"},
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	// Note: The Rules pushed later come first.
+	expected := []string{
+		otherID.String() + " accept",
+		ruleIDs[2] + " accept",
+		ruleIDs[1] + " jump",
+		ruleIDs[0] + " drop",
+	}
+	check := func(what string) {
+		var got []string
+		for _, rule := range s.Rules(chainID.String()) {
+			got = append(got, fmt.Sprintf("%v %v", rule["id"], rule["type"]))
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: got %v\nwant %v", what, got, expected)
+		}
+	}
+
+	// Repair the Rule in the middle of the Chain
+	s.Modify(fmt.Sprintf("/rules/%s", ruleIDs[1]), map[string]interface{}{"type": "return"})
+	s.ResetRequests()
+	newDriftChecker(store, record.NewFakeRecorder(10), config, cfg).checkAll(context.Background())
+	check("different")
+	// The other Rules are left intact.
+	for _, req := range s.Requests() {
+		if req == fmt.Sprintf("DELETE /rules/%s", ruleIDs[0]) || req == fmt.Sprintf("DELETE /rules/%s", otherID) {
+			t.Errorf("unexpected request %s", req)
+		}
+	}
+
+	// Re-create missing Rules
+	s.Remove(fmt.Sprintf("/rules/%s", ruleIDs[2]))
+	newDriftChecker(store, record.NewFakeRecorder(10), config, cfg).checkAll(context.Background())
+	check("missing")
+	s.Remove(fmt.Sprintf("/rules/%s", ruleIDs[0]))
+	newDriftChecker(store, record.NewFakeRecorder(10), config, cfg).checkAll(context.Background())
+	check("missing last")
+}
+
+func TestDriftSkipsNewGeneration(t *testing.T) {
+	s := fake.NewServer()
+	defer s.Close()
+	config := midonet.NewConfigFromEnvConfig(&config.Config{MidoNetAPI: s.URL})
+	bridgeID := uuid.New()
+	bridge := &midonet.Bridge{ID: &bridgeID, Name: "b"}
+	tr := &mnv1.Translation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "kube-system",
+			Name:       "test",
+			Generation: 2,
+		},
+		Status: mnv1.TranslationStatus{
+			Phase:              mnv1.TranslationSynced,
+			ObservedGeneration: 1,
+		},
+		Resources: []mnv1.BackendResource{toAPI(t, bridge)},
+	}
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	store.Add(tr)
+	cfg := &converter.Config{}
+	cfg.SetReloadable(converter.ReloadableConfig{DriftRepair: true})
+	// The new generation is left to the pusher.
+	newDriftChecker(store, record.NewFakeRecorder(10), config, cfg).checkAll(context.Background())
+	if s.Exists(fmt.Sprintf("/bridges/%s", bridgeID)) {
+		t.Errorf("Bridge pushed by the drift checker")
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pusher
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+
+	mnv1 "github.com/midonet/midonet-kubernetes/pkg/apis/midonet/v1"
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+var (
+	orphanGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "midonet_kube_controllers",
+			Subsystem: "pusher",
+			Name:      "gc_orphan_resources",
+			Help:      "Number of backend resources not in any Translations, found by the last garbage collection",
+		},
+		[]string{"kind"},
+	)
+
+	gcDeleteCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "midonet_kube_controllers",
+			Subsystem: "pusher",
+			Name:      "gc_deleted_resources_total",
+			Help:      "Number of backend resources deleted by the garbage collection",
+		},
+		[]string{"kind"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(orphanGauge)
+	prometheus.MustRegister(gcDeleteCount)
+}
+
+// garbageCollector periodically deletes the backend resources owned by
+// our tenant which no Translations have.  They can be left behind e.g.
+// when the finalizer of a Translation was removed by hand.
+type garbageCollector struct {
+	store  cache.Store
+	client *midonet.Client
+	config *converter.Config
+}
+
+func newGarbageCollector(store cache.Store, config *midonet.Config, converterConfig *converter.Config) *garbageCollector {
+	return &garbageCollector{
+		store:  store,
+		client: midonet.NewClient(config),
+		config: converterConfig,
+	}
+}
+
+func (gc *garbageCollector) run(informer cache.SharedIndexInformer, interval time.Duration, stop <-chan struct{}) {
+	if !cache.WaitForCacheSync(stop, informer.HasSynced) {
+		return
+	}
+	ctx, cancel := contextForStop(stop)
+	defer cancel()
+	wait.Until(func() { gc.collect(ctx) }, interval, stop)
+}
+
+// contextForStop returns a context which is canceled when the stop
+// channel is closed.
+func contextForStop(stop <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func parentID(res midonet.APIResource) *uuid.UUID {
+	if p, ok := res.(midonet.HasParent); ok {
+		return p.GetParent()
+	}
+	return nil
+}
+
+func (gc *garbageCollector) referencedIDs() (map[uuid.UUID]bool, error) {
+	ids := make(map[uuid.UUID]bool)
+	for _, obj := range gc.store.List() {
+		tr := obj.(*mnv1.Translation)
+		for _, r := range tr.Resources {
+			res, err := midonet.FromAPI(r)
+			if err != nil {
+				return nil, err
+			}
+			if id := midonet.ResourceID(res); id != nil {
+				ids[*id] = true
+			}
+		}
+	}
+	return ids, nil
+}
+
+// orphans returns the resources which are not referenced.
+// A resource with referenced children is not an orphan because
+// its deletion would cascade-delete them.
+func orphans(resources []midonet.APIResource, referenced map[uuid.UUID]bool) []midonet.APIResource {
+	inUse := make(map[uuid.UUID]bool)
+	for _, res := range resources {
+		id := midonet.ResourceID(res)
+		if id == nil || !referenced[*id] {
+			continue
+		}
+		inUse[*id] = true
+		if parent := parentID(res); parent != nil {
+			inUse[*parent] = true
+		}
+	}
+	var result []midonet.APIResource
+	for _, res := range resources {
+		id := midonet.ResourceID(res)
+		if id != nil && !inUse[*id] {
+			result = append(result, res)
+		}
+	}
+	return result
+}
+
+func (gc *garbageCollector) collect(ctx context.Context) {
+	tenant := gc.config.Tenant
+	reloadable := gc.config.Reloadable()
+	clog := log.WithFields(log.Fields{
+		"tenant": tenant,
+		"dryRun": reloadable.GCDryRun,
+	})
+	clog.Debug("Start garbage collection")
+	// Note: List the backend first.  Anything pushed to the backend
+	// has its Translation in the store by then.
+	resources, err := gc.client.ListTenantResources(ctx, tenant)
+	if err != nil {
+		clog.WithError(err).Warn("Failed to list backend resources")
+		return
+	}
+	if len(gc.store.List()) == 0 {
+		clog.Warn("No Translations found.  Skipping garbage collection")
+		return
+	}
+	referenced, err := gc.referencedIDs()
+	if err != nil {
+		clog.WithError(err).Error("Failed to parse Translations.  Skipping garbage collection")
+		return
+	}
+	garbage := orphans(resources, referenced)
+	orphanGauge.Reset()
+	for _, res := range garbage {
+		orphanGauge.With(prometheus.Labels{"kind": midonet.TypeNameForObject(res)}).Inc()
+	}
+	if len(garbage) == 0 {
+		clog.Debug("No garbage found")
+		return
+	}
+	clog = clog.WithFields(log.Fields{
+		"garbage": len(garbage),
+		"total":   len(resources),
+	})
+	if len(garbage)*100 > len(resources)*reloadable.GCMaxDeletePercent {
+		clog.Error("Too many garbage resources.  Refusing to delete them")
+		return
+	}
+	for _, res := range garbage {
+		if ctx.Err() != nil {
+			return
+		}
+		kind := midonet.TypeNameForObject(res)
+		rlog := clog.WithFields(log.Fields{
+			"kind": kind,
+			"id":   midonet.ResourceID(res),
+		})
+		if reloadable.GCDryRun {
+			rlog.Info("Found garbage")
+			continue
+		}
+		err := gc.client.Delete(ctx, []midonet.APIResource{res})
+		if err != nil {
+			rlog.WithError(err).Warn("Failed to delete garbage")
+			continue
+		}
+		gcDeleteCount.With(prometheus.Labels{"kind": kind}).Inc()
+		rlog.Info("Deleted garbage")
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pusher
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	mnv1 "github.com/midonet/midonet-kubernetes/pkg/apis/midonet/v1"
+	"github.com/midonet/midonet-kubernetes/pkg/config"
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet/fake"
+)
+
+func TestGarbageCollector(t *testing.T) {
+	s := fake.NewServer()
+	defer s.Close()
+	config := midonet.NewConfigFromEnvConfig(&config.Config{MidoNetAPI: s.URL})
+	bridgeID := uuid.New()
+	portID := uuid.New()
+	chainID := uuid.New()
+	ruleID := uuid.New()
+	garbageChainID := uuid.New()
+	garbageRuleID := uuid.New()
+	otherChainID := uuid.New()
+	live := []midonet.APIResource{
+		&midonet.Bridge{ID: &bridgeID, TenantID: "midonetkube"},
+		&midonet.Port{Parent: midonet.Parent{ID: &bridgeID}, ID: &portID, Type: "Bridge"},
+		&midonet.Chain{ID: &chainID, TenantID: "midonetkube"},
+		&midonet.Rule{Parent: midonet.Parent{ID: &chainID}, ID: &ruleID, Type: "accept"},
+	}
+	garbage := []midonet.APIResource{
+		&midonet.Chain{ID: &garbageChainID, TenantID: "midonetkube"},
+		&midonet.Rule{Parent: midonet.Parent{ID: &chainID}, ID: &garbageRuleID, Type: "drop"},
+		&midonet.Chain{ID: &otherChainID, TenantID: "someone-else"},
+	}
+	err := midonet.NewClient(config).Push(context.Background(), append(live, garbage...))
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	tr := &mnv1.Translation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "kube-system",
+			Name:      "test",
+		},
+	}
+	for _, res := range live {
+		tr.Resources = append(tr.Resources, toAPI(t, res.(converter.BackendResource)))
+	}
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	store.Add(tr)
+	cfg := &converter.Config{Tenant: "midonetkube"}
+	cfg.SetReloadable(converter.ReloadableConfig{
+		GCDryRun:           true,
+		GCMaxDeletePercent: 100,
+	})
+	all := s.Paths()
+
+	newGarbageCollector(store, config, cfg).collect(context.Background())
+	if !reflect.DeepEqual(s.Paths(), all) {
+		t.Errorf("dry-run deleted something: %v", s.Paths())
+	}
+
+	// 2 of 6 are garbage
+	cfg.SetReloadable(converter.ReloadableConfig{
+		GCMaxDeletePercent: 30,
+	})
+	newGarbageCollector(store, config, cfg).collect(context.Background())
+	if !reflect.DeepEqual(s.Paths(), all) {
+		t.Errorf("deleted beyond the threshold: %v", s.Paths())
+	}
+
+	cfg.SetReloadable(converter.ReloadableConfig{
+		GCMaxDeletePercent: 40,
+	})
+	newGarbageCollector(store, config, cfg).collect(context.Background())
+	if s.Exists(fmt.Sprintf("/chains/%s", garbageChainID)) {
+		t.Errorf("chain not deleted")
+	}
+	if s.Exists(fmt.Sprintf("/rules/%s", garbageRuleID)) {
+		t.Errorf("rule not deleted")
+	}
+	for _, p := range []string{
+		fmt.Sprintf("/bridges/%s", bridgeID),
+		fmt.Sprintf("/ports/%s", portID),
+		fmt.Sprintf("/chains/%s", chainID),
+		fmt.Sprintf("/rules/%s", ruleID),
+		fmt.Sprintf("/chains/%s", otherChainID),
+	} {
+		if !s.Exists(p) {
+			t.Errorf("%s deleted", p)
+		}
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	chainID := uuid.New()
+	ruleID := uuid.New()
+	chain := &midonet.Chain{ID: &chainID}
+	rule := &midonet.Rule{Parent: midonet.Parent{ID: &chainID}, ID: &ruleID}
+	// The chain is not referenced but has a referenced rule
+	actual := orphans([]midonet.APIResource{rule, chain}, map[uuid.UUID]bool{ruleID: true})
+	if len(actual) != 0 {
+		t.Errorf("got %v\nwant nothing", actual)
+	}
+}
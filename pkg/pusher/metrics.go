@@ -0,0 +1,107 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pusher
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"k8s.io/client-go/tools/cache"
+
+	mnv1 "github.com/midonet/midonet-kubernetes/pkg/apis/midonet/v1"
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+)
+
+var (
+	pushLag = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: "midonet_kube_controllers",
+			Subsystem: "pusher",
+			Name:      "lag_seconds",
+			Help:      "Time from a change of a Translation to its successful push",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 16),
+		},
+	)
+
+	translationsDesc = prometheus.NewDesc(
+		"midonet_kube_controllers_pusher_translations",
+		"Number of Translations",
+		[]string{"kind", "phase"},
+		nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(pushLag)
+}
+
+// observeLag records the time since the converter updated the Translation.
+func observeLag(tr *mnv1.Translation) {
+	updatedAt, ok := tr.ObjectMeta.Annotations[converter.UpdatedAtAnnotation]
+	if !ok {
+		// Created by an older version
+		return
+	}
+	t, err := time.Parse(time.RFC3339Nano, updatedAt)
+	if err != nil {
+		log.WithError(err).WithField("updatedAt", updatedAt).Warn("Unparsable timestamp")
+		return
+	}
+	pushLag.Observe(time.Since(t).Seconds())
+}
+
+// translationCollector counts the Translations in the informer cache
+// when scraped.
+type translationCollector struct {
+	store cache.Store
+}
+
+func newTranslationCollector(store cache.Store) prometheus.Collector {
+	return &translationCollector{store: store}
+}
+
+func (c *translationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- translationsDesc
+}
+
+func (c *translationCollector) Collect(ch chan<- prometheus.Metric) {
+	counts := make(map[[2]string]int)
+	for _, obj := range c.store.List() {
+		tr := obj.(*mnv1.Translation)
+		phase := tr.Status.Phase
+		if phase == "" {
+			phase = mnv1.TranslationPending
+		}
+		counts[[2]string{translationKind(tr), string(phase)}]++
+	}
+	for labels, count := range counts {
+		ch <- prometheus.MustNewConstMetric(translationsDesc, prometheus.GaugeValue, float64(count), labels[0], labels[1])
+	}
+}
+
+// translationKind returns the kind of the Kubernetes resource
+// the Translation was created for.
+func translationKind(tr *mnv1.Translation) string {
+	if _, ok := tr.ObjectMeta.Labels[converter.GlobalLabel]; ok {
+		return "Global"
+	}
+	for _, owner := range tr.ObjectMeta.OwnerReferences {
+		return owner.Kind
+	}
+	return "Unknown"
+}
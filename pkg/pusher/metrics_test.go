@@ -0,0 +1,63 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pusher
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	mnv1 "github.com/midonet/midonet-kubernetes/pkg/apis/midonet/v1"
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+)
+
+func TestTranslationCollector(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	store.Add(&mnv1.Translation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "kube-system",
+			Name:      "global",
+			Labels:    map[string]string{converter.GlobalLabel: ""},
+		},
+	})
+	for _, name := range []string{"svc1", "svc2"} {
+		store.Add(&mnv1.Translation{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "default",
+				Name:            name,
+				OwnerReferences: []metav1.OwnerReference{{Kind: "Service"}},
+			},
+			Status: mnv1.TranslationStatus{Phase: mnv1.TranslationSynced},
+		})
+	}
+	ch := make(chan prometheus.Metric, 10)
+	newTranslationCollector(store).Collect(ch)
+	close(ch)
+	if len(ch) != 2 {
+		t.Errorf("got %d metrics\nwant 2", len(ch))
+	}
+	tr, _, _ := store.GetByKey("default/svc1")
+	if kind := translationKind(tr.(*mnv1.Translation)); kind != "Service" {
+		t.Errorf("got %s\nwant Service", kind)
+	}
+	tr, _, _ = store.GetByKey("kube-system/global")
+	if kind := translationKind(tr.(*mnv1.Translation)); kind != "Global" {
+		t.Errorf("got %s\nwant Global", kind)
+	}
+}
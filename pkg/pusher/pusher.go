@@ -16,10 +16,14 @@
 package pusher
 
 import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 
 	mnv1 "github.com/midonet/midonet-kubernetes/pkg/apis/midonet/v1"
@@ -29,20 +33,43 @@ import (
 	"github.com/midonet/midonet-kubernetes/pkg/util"
 )
 
+var errorCount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "midonet_kube_controllers",
+		Subsystem: "pusher",
+		Name:      "errors_total",
+		Help:      "Number of failed Translation pushes and deletions",
+	},
+	[]string{"operation", "reason"},
+)
+
+func init() {
+	prometheus.MustRegister(errorCount)
+}
+
 type pusherHandler struct {
-	mncli    *mncli.Clientset
+	mncli    mncli.Interface
 	client   *midonet.Client
 	recorder record.EventRecorder
 	config   *midonet.Config
+	deps     *dependencyTracker
+
+	// resync is nil unless the bulk resync is enabled.
+	resync *bulkResync
+
+	// ctx is canceled when the controller fails to stop in time.
+	ctx context.Context
 }
 
-func newHandler(mc *mncli.Clientset, recorder record.EventRecorder, config *midonet.Config) *pusherHandler {
+func newHandler(mc mncli.Interface, recorder record.EventRecorder, config *midonet.Config, indexer cache.Indexer) *pusherHandler {
 	client := midonet.NewClient(config)
 	return &pusherHandler{
 		mncli:    mc,
 		client:   client,
 		recorder: recorder,
 		config:   config,
+		deps:     newDependencyTracker(indexer),
+		ctx:      context.Background(),
 	}
 }
 
@@ -60,17 +87,52 @@ func (h *pusherHandler) Update(key string, gvk schema.GroupVersionKind, obj inte
 		resources = append(resources, r)
 	}
 	if tr.ObjectMeta.DeletionTimestamp == nil {
+		if inSync(tr) {
+			clog.Debug("Translation is in sync")
+			h.deps.setSynced(key, tr, true)
+			return nil
+		}
+		if provider := h.deps.waitFor(key, resources); provider != "" {
+			clog.WithField("provider", provider).Info("Waiting for the referents to be pushed")
+			h.updateStatus(tr, pendingStatus(tr))
+			return nil
+		}
+		if h.resync != nil && h.resync.upToDate(h.ctx, resources) {
+			clog.Debug("Translation is up to date in the snapshot")
+			h.updateStatus(tr, syncedStatus(tr, allSynced(tr)))
+			h.deps.setSynced(key, tr, true)
+			return nil
+		}
 		clog.Debug("Handling Translation Update")
-		err := h.client.Push(resources)
+		h.deps.setSynced(key, tr, false)
+		status, err := h.push(tr, resources)
+		if midonet.IsCanceled(err) {
+			// Stopping.  Leave the status to the next leader.
+			clog.Info("Push canceled")
+			return err
+		}
+		h.updateStatus(tr, status)
 		if err != nil {
+			h.countError(clog, "update", err)
 			h.recorder.Eventf(tr, v1.EventTypeWarning, "TranslationUpdateError", "Translation Update failed with error %v", err)
 			return err
 		}
 		h.recorder.Event(tr, v1.EventTypeNormal, "TranslationUpdatePushed", "Translation Update pushed to the backend")
+		observeLag(tr)
+		h.deps.setSynced(key, tr, true)
 	} else {
 		clog.Debug("Handling Translation Deletion")
-		err := h.client.Delete(resources)
+		if h.resync != nil {
+			h.resync.forget(resources)
+		}
+		err := h.client.Delete(h.ctx, resources)
+		if midonet.IsCanceled(err) {
+			clog.Info("Deletion canceled")
+			return err
+		}
 		if err != nil {
+			h.countError(clog, "delete", err)
+			h.updateStatus(tr, errorStatus(tr, err))
 			h.recorder.Eventf(tr, v1.EventTypeWarning, "TranslationDeletionError", "Translation Deletion failed with error %v", err)
 			return err
 		}
@@ -85,6 +147,21 @@ func (h *pusherHandler) Update(key string, gvk schema.GroupVersionKind, obj inte
 	return nil
 }
 
+// countError records a failure of the backend operation.
+// The error is returned to the controller, which retries the Translation
+// with a backoff.
+func (h *pusherHandler) countError(clog *log.Entry, operation string, err error) {
+	reason := midonet.Reason(err)
+	clog.WithFields(log.Fields{
+		"operation": operation,
+		"reason":    reason,
+	}).WithError(err).Warn("Backend operation failed")
+	errorCount.With(prometheus.Labels{
+		"operation": operation,
+		"reason":    string(reason),
+	}).Inc()
+}
+
 func (h *pusherHandler) clearFinalizer(tr *mnv1.Translation) error {
 	ns := tr.ObjectMeta.Namespace
 	new := tr.DeepCopy()
@@ -99,6 +176,6 @@ func (h *pusherHandler) clearFinalizer(tr *mnv1.Translation) error {
 }
 
 func (h *pusherHandler) Delete(key string) error {
-	/* nothing to do */
+	h.deps.forget(key)
 	return nil
 }
@@ -0,0 +1,191 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pusher
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	mnv1 "github.com/midonet/midonet-kubernetes/pkg/apis/midonet/v1"
+	mnfake "github.com/midonet/midonet-kubernetes/pkg/client/clientset/versioned/fake"
+	"github.com/midonet/midonet-kubernetes/pkg/config"
+	"github.com/midonet/midonet-kubernetes/pkg/converter"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet/fake"
+)
+
+func toAPI(t *testing.T, res converter.BackendResource) mnv1.BackendResource {
+	r, err := res.ToAPI(res)
+	if err != nil {
+		t.Fatalf("ToAPI: %v", err)
+	}
+	return *r
+}
+
+func TestUpdate(t *testing.T) {
+	s := fake.NewServer()
+	defer s.Close()
+	bridgeID := uuid.New()
+	portID := uuid.New()
+	tr := &mnv1.Translation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "kube-system",
+			Name:       "test",
+			Generation: 1,
+			Finalizers: []string{converter.MidoNetAPIDeleter},
+		},
+		Resources: []mnv1.BackendResource{
+			// In the reverse order to see the failure first
+			toAPI(t, &midonet.Port{Parent: midonet.Parent{ID: &bridgeID}, ID: &portID, Type: "Bridge"}),
+			toAPI(t, &midonet.Bridge{ID: &bridgeID}),
+		},
+	}
+	mc := mnfake.NewSimpleClientset(tr)
+	config := midonet.NewConfigFromEnvConfig(&config.Config{MidoNetAPI: s.URL})
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{providedIDIndex: providedIDs})
+	h := newHandler(mc, record.NewFakeRecorder(10), config, indexer)
+
+	err := h.Update("kube-system/test", mnv1.SchemeGroupVersion.WithKind("Translation"), tr)
+	if !midonet.IsNotFound(err) {
+		t.Fatalf("got %v\nwant NotFound", err)
+	}
+	tr, _ = mc.MidonetV1().Translations("kube-system").Get("test", metav1.GetOptions{})
+	if tr.Status.Phase != mnv1.TranslationError || tr.Status.Resources[0].Phase != mnv1.TranslationError || tr.Status.Resources[1].Phase != mnv1.TranslationPending {
+		t.Errorf("unexpected status %v", tr.Status)
+	}
+
+	tr.Resources[0], tr.Resources[1] = tr.Resources[1], tr.Resources[0]
+	err = h.Update("kube-system/test", mnv1.SchemeGroupVersion.WithKind("Translation"), tr)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !s.Exists(fmt.Sprintf("/ports/%s", portID)) {
+		t.Errorf("port doesn't exist")
+	}
+	tr, _ = mc.MidonetV1().Translations("kube-system").Get("test", metav1.GetOptions{})
+	if tr.Status.Phase != mnv1.TranslationSynced || tr.Status.ObservedGeneration != 1 {
+		t.Errorf("unexpected status %v", tr.Status)
+	}
+
+	// In sync; no backend calls
+	s.ResetRequests()
+	err = h.Update("kube-system/test", mnv1.SchemeGroupVersion.WithKind("Translation"), tr)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if len(s.Requests()) != 0 {
+		t.Errorf("got %v\nwant no requests", s.Requests())
+	}
+
+	now := metav1.Now()
+	tr.ObjectMeta.DeletionTimestamp = &now
+	err = h.Update("kube-system/test", mnv1.SchemeGroupVersion.WithKind("Translation"), tr)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if len(s.Paths()) != 0 {
+		t.Errorf("got %v\nwant nothing", s.Paths())
+	}
+	tr, _ = mc.MidonetV1().Translations("kube-system").Get("test", metav1.GetOptions{})
+	if len(tr.ObjectMeta.Finalizers) != 0 {
+		t.Errorf("got finalizers %v", tr.ObjectMeta.Finalizers)
+	}
+}
+
+func TestConcurrentUpdate(t *testing.T) {
+	// The workers share the handler and thus its Client.
+	// Run with -race to check it's safe.
+	s := fake.NewServer()
+	defer s.Close()
+	s.RequireAuth("admin", "secret")
+	config := midonet.NewConfigFromEnvConfig(&config.Config{
+		MidoNetAPI:      s.URL,
+		MidoNetUserName: "admin",
+		MidoNetPassword: "secret",
+	})
+	const n = 8
+	var trs []*mnv1.Translation
+	var bridgeIDs []uuid.UUID
+	mc := mnfake.NewSimpleClientset()
+	for i := 0; i < n; i++ {
+		bridgeID := uuid.New()
+		// Note: Generation 0 makes the Translation never in sync.
+		tr := &mnv1.Translation{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "kube-system",
+				Name:      fmt.Sprintf("test-%d", i),
+			},
+			Resources: []mnv1.BackendResource{
+				toAPI(t, &midonet.Bridge{ID: &bridgeID}),
+			},
+		}
+		mc.MidonetV1().Translations("kube-system").Create(tr)
+		trs = append(trs, tr)
+		bridgeIDs = append(bridgeIDs, bridgeID)
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{providedIDIndex: providedIDs})
+	h := newHandler(mc, record.NewFakeRecorder(10*n), config, indexer)
+
+	gvk := mnv1.SchemeGroupVersion.WithKind("Translation")
+	updateAll := func() {
+		var wg sync.WaitGroup
+		for _, tr := range trs {
+			wg.Add(1)
+			go func(tr *mnv1.Translation) {
+				defer wg.Done()
+				key := "kube-system/" + tr.ObjectMeta.Name
+				if err := h.Update(key, gvk, tr); err != nil {
+					t.Errorf("Update %s: %v", key, err)
+				}
+			}(tr)
+		}
+		wg.Wait()
+	}
+	logins := func() int {
+		count := 0
+		for _, r := range s.Requests() {
+			if r == "POST /login" {
+				count++
+			}
+		}
+		return count
+	}
+
+	updateAll()
+	for _, id := range bridgeIDs {
+		if !s.Exists(fmt.Sprintf("/bridges/%s", id)) {
+			t.Errorf("bridge %s doesn't exist", id)
+		}
+	}
+	if c := logins(); c != 1 {
+		t.Errorf("got %d logins\nwant 1", c)
+	}
+
+	// The workers log in again only once on an expired token
+	s.ResetRequests()
+	s.ExpireTokens()
+	updateAll()
+	if c := logins(); c != 1 {
+		t.Errorf("got %d logins\nwant 1", c)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pusher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+// snapshotMaxAge is how long a snapshot is trusted.  The initial
+// resync should be done by then.  Later pushes are for changes made
+// after the snapshot anyway.
+const snapshotMaxAge = 10 * time.Minute
+
+var resyncSkipCount = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "midonet_kube_controllers",
+		Subsystem: "pusher",
+		Name:      "resync_skipped_translations_total",
+		Help:      "Number of Translations found up to date in the startup snapshot and not pushed",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(resyncSkipCount)
+}
+
+// bulkResync skips pushes of Translations whose resources are already
+// on the backend, using a snapshot taken with a few collection listings
+// when the pusher handles the first Translation.  It saves thousands of
+// POST/PUT round trips on a restart of the controllers, which
+// re-handles every Translation.
+type bulkResync struct {
+	client *midonet.Client
+	tenant string
+
+	mu       sync.Mutex
+	snapshot *midonet.Snapshot
+	taken    time.Time
+	done     bool
+}
+
+func newBulkResync(config *midonet.Config, tenant string) *bulkResync {
+	return &bulkResync{
+		client: midonet.NewClient(config),
+		tenant: tenant,
+	}
+}
+
+// upToDate returns true if all of the given resources are same in
+// the snapshot.  The resources are forgotten from the snapshot either
+// way because they are pushed or considered synced from now on.
+// The snapshot is taken on the first call.  Other workers wait for it.
+func (r *bulkResync) upToDate(ctx context.Context, resources []midonet.APIResource) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done {
+		return false
+	}
+	if r.snapshot == nil {
+		start := time.Now()
+		snapshot, err := r.client.TakeSnapshot(ctx, r.tenant)
+		if err != nil {
+			log.WithError(err).Warn("Failed to take a snapshot of the backend.  Pushing Translations one by one")
+			r.done = true
+			return false
+		}
+		log.WithFields(log.Fields{
+			"resources": snapshot.Len(),
+			"duration":  time.Since(start),
+		}).Info("Took a snapshot of the backend")
+		r.snapshot = snapshot
+		r.taken = time.Now()
+	}
+	if time.Since(r.taken) > snapshotMaxAge {
+		log.Info("Discarding the snapshot of the backend")
+		r.snapshot = nil
+		r.done = true
+		return false
+	}
+	result := len(resources) > 0
+	for _, res := range resources {
+		if !r.snapshot.UpToDate(res) {
+			result = false
+		}
+		r.snapshot.Forget(res)
+	}
+	if result {
+		resyncSkipCount.Inc()
+	}
+	return result
+}
+
+// forget removes the resources from the snapshot.
+func (r *bulkResync) forget(resources []midonet.APIResource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.snapshot == nil {
+		return
+	}
+	for _, res := range resources {
+		r.snapshot.Forget(res)
+	}
+}
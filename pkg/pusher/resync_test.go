@@ -0,0 +1,140 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pusher
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	mnv1 "github.com/midonet/midonet-kubernetes/pkg/apis/midonet/v1"
+	mnfake "github.com/midonet/midonet-kubernetes/pkg/client/clientset/versioned/fake"
+	"github.com/midonet/midonet-kubernetes/pkg/config"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet/fake"
+)
+
+// writes returns the requests which modify the backend.
+func writes(requests []string) []string {
+	var result []string
+	for _, r := range requests {
+		if !strings.HasPrefix(r, "GET ") {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+func TestBulkResync(t *testing.T) {
+	s := fake.NewServer()
+	defer s.Close()
+	config := midonet.NewConfigFromEnvConfig(&config.Config{MidoNetAPI: s.URL})
+	bridgeID := uuid.New()
+	portID := uuid.New()
+	chainID := uuid.New()
+	ruleID := uuid.New()
+	bridge := &midonet.Bridge{ID: &bridgeID, TenantID: "midonetkube", Name: "bridge"}
+	port := &midonet.Port{Parent: midonet.Parent{ID: &bridgeID}, ID: &portID, Type: "Bridge"}
+	chain := &midonet.Chain{ID: &chainID, TenantID: "midonetkube", Name: "chain"}
+	rule := &midonet.Rule{Parent: midonet.Parent{ID: &chainID}, ID: &ruleID, Type: "accept"}
+	err := midonet.NewClient(config).Push(context.Background(), []midonet.APIResource{bridge, port, chain, rule})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	// Note: Generation 0 makes the Translations never in sync, as on
+	// an apiserver without the status subresource.
+	unchanged := &mnv1.Translation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "unchanged"},
+		Resources: []mnv1.BackendResource{
+			toAPI(t, bridge),
+			toAPI(t, port),
+		},
+	}
+	rule.Type = "drop"
+	changed := &mnv1.Translation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "changed"},
+		Resources: []mnv1.BackendResource{
+			toAPI(t, chain),
+			toAPI(t, rule),
+		},
+	}
+	mc := mnfake.NewSimpleClientset(unchanged, changed)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{providedIDIndex: providedIDs})
+	h := newHandler(mc, record.NewFakeRecorder(10), config, indexer)
+	h.resync = newBulkResync(config, "midonetkube")
+	s.ResetRequests()
+
+	gvk := mnv1.SchemeGroupVersion.WithKind("Translation")
+	err = h.Update("kube-system/unchanged", gvk, unchanged)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if w := writes(s.Requests()); len(w) != 0 {
+		t.Errorf("got %v\nwant no writes", w)
+	}
+	tr, _ := mc.MidonetV1().Translations("kube-system").Get("unchanged", metav1.GetOptions{})
+	if tr.Status.Phase != mnv1.TranslationSynced || len(tr.Status.Resources) != 2 {
+		t.Errorf("unexpected status %v", tr.Status)
+	}
+
+	// The snapshot is taken only once
+	s.ResetRequests()
+	err = h.Update("kube-system/changed", gvk, changed)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	for _, r := range s.Requests() {
+		if r == "GET /chains" {
+			t.Errorf("got %v\nwant no listing", r)
+		}
+	}
+	if len(writes(s.Requests())) == 0 {
+		t.Errorf("got %v\nwant the changed Translation pushed", s.Requests())
+	}
+
+	// The resources are forgotten once handled
+	s.ResetRequests()
+	err = h.Update("kube-system/unchanged", gvk, unchanged)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if len(writes(s.Requests())) == 0 {
+		t.Errorf("got %v\nwant the Translation pushed", s.Requests())
+	}
+}
+
+func TestBulkResyncFailure(t *testing.T) {
+	s := fake.NewServer()
+	defer s.Close()
+	config := midonet.NewConfigFromEnvConfig(&config.Config{MidoNetAPI: s.URL})
+	r := newBulkResync(config, "midonetkube")
+	bridgeID := uuid.New()
+	bridge := &midonet.Bridge{ID: &bridgeID, TenantID: "midonetkube"}
+	s.FailNext("GET", "/routers", 503)
+	if r.upToDate(context.Background(), []midonet.APIResource{bridge}) {
+		t.Errorf("got true\nwant false")
+	}
+	if !r.done {
+		t.Errorf("the bulk resync should be given up")
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (C) 2018 Midokura SARL.
+// All rights reserved.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package pusher
+
+import (
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mnv1 "github.com/midonet/midonet-kubernetes/pkg/apis/midonet/v1"
+	"github.com/midonet/midonet-kubernetes/pkg/midonet"
+)
+
+// inSync returns true if the given Translation has already been pushed
+// to the backend.
+// Note: Generation is always 0 if the apiserver doesn't support
+// the status subresource for custom resources.  In that case, we can't
+// tell if the Translation has been updated since the last push.
+func inSync(tr *mnv1.Translation) bool {
+	return tr.ObjectMeta.Generation != 0 &&
+		tr.Status.ObservedGeneration == tr.ObjectMeta.Generation &&
+		tr.Status.Phase == mnv1.TranslationSynced
+}
+
+// push pushes the resources one by one to record the result of each of them.
+func (h *pusherHandler) push(tr *mnv1.Translation, resources []midonet.APIResource) (*mnv1.TranslationStatus, error) {
+	results := make([]mnv1.BackendResourceStatus, len(resources))
+	for i := range resources {
+		results[i] = mnv1.BackendResourceStatus{
+			Kind:  tr.Resources[i].Kind,
+			Phase: mnv1.TranslationPending,
+		}
+	}
+	for i, res := range resources {
+		err := h.client.Push(h.ctx, []midonet.APIResource{res})
+		if err != nil {
+			results[i].Phase = mnv1.TranslationError
+			results[i].Error = err.Error()
+			status := errorStatus(tr, err)
+			status.Resources = results
+			return status, err
+		}
+		results[i].Phase = mnv1.TranslationSynced
+	}
+	return syncedStatus(tr, results), nil
+}
+
+func syncedStatus(tr *mnv1.Translation, results []mnv1.BackendResourceStatus) *mnv1.TranslationStatus {
+	now := metav1.Now()
+	return &mnv1.TranslationStatus{
+		ObservedGeneration: tr.ObjectMeta.Generation,
+		Phase:              mnv1.TranslationSynced,
+		LastSyncTime:       &now,
+		Resources:          results,
+	}
+}
+
+// allSynced returns the statuses of the resources of a Translation
+// which is known to be synced without a push.
+func allSynced(tr *mnv1.Translation) []mnv1.BackendResourceStatus {
+	results := make([]mnv1.BackendResourceStatus, len(tr.Resources))
+	for i, r := range tr.Resources {
+		results[i] = mnv1.BackendResourceStatus{
+			Kind:  r.Kind,
+			Phase: mnv1.TranslationSynced,
+		}
+	}
+	return results
+}
+
+func errorStatus(tr *mnv1.Translation, err error) *mnv1.TranslationStatus {
+	status := tr.Status.DeepCopy()
+	status.ObservedGeneration = tr.ObjectMeta.Generation
+	status.Phase = mnv1.TranslationError
+	status.LastError = err.Error()
+	return status
+}
+
+func pendingStatus(tr *mnv1.Translation) *mnv1.TranslationStatus {
+	status := tr.Status.DeepCopy()
+	status.ObservedGeneration = tr.ObjectMeta.Generation
+	status.Phase = mnv1.TranslationPending
+	return status
+}
+
+// sameStatus compares the statuses, ignoring LastSyncTime.
+// Without this, a status update would trigger another push and
+// status update, forever, when Generation is not available.
+func sameStatus(a, b *mnv1.TranslationStatus) bool {
+	return a.ObservedGeneration == b.ObservedGeneration &&
+		a.Phase == b.Phase &&
+		a.LastError == b.LastError &&
+		reflect.DeepEqual(a.Resources, b.Resources)
+}
+
+// updateStatus writes the status of the Translation.
+// A failure is only logged because the status is merely informational
+// and the next event for the Translation will write it again.
+func (h *pusherHandler) updateStatus(tr *mnv1.Translation, status *mnv1.TranslationStatus) {
+	if sameStatus(&tr.Status, status) {
+		/* nothing to do */
+		return
+	}
+	clog := log.WithFields(log.Fields{
+		"namespace": tr.ObjectMeta.Namespace,
+		"name":      tr.ObjectMeta.Name,
+		"phase":     status.Phase,
+	})
+	ns := tr.ObjectMeta.Namespace
+	new := tr.DeepCopy()
+	new.Status = *status
+	_, err := h.mncli.MidonetV1().Translations(ns).UpdateStatus(new)
+	if errors.IsNotFound(err) {
+		// The apiserver might not support the status subresource
+		// for custom resources.  (Kubernetes v1.10 without
+		// CustomResourceSubresources feature gate)  In that case,
+		// the status is a part of the main resource.
+		_, err = h.mncli.MidonetV1().Translations(ns).Update(new)
+	}
+	if err != nil {
+		clog.WithError(err).Warn("Failed to update status")
+		return
+	}
+	clog.Debug("Updated status")
+}